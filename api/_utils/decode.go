@@ -27,3 +27,16 @@ func DecodeRecipientParams(r *http.Request) (params *recipientParams, err error)
 
 	return
 }
+
+// DecodeQueryParams decodes the query parameters of the request into params, using its schema tags.
+func DecodeQueryParams(r *http.Request, params any) (err error) {
+	decoder.IgnoreUnknownKeys(true)
+	if err = decoder.Decode(params, r.URL.Query()); err != nil {
+		log.Println(err)
+
+		err = errors.NewResponseError(errors.BAD_REQUEST_ERROR, http.StatusBadRequest)
+		return
+	}
+
+	return
+}
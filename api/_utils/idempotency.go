@@ -0,0 +1,168 @@
+package api_utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/db"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER      = "Idempotency-Key"
+	IDEMPOTENT_REPLAYED_HEADER  = "Idempotent-Replayed"
+	MAX_IDEMPOTENT_REQUEST_SIZE = 1 << 20
+)
+
+// responseRecorder passes the response through to the underlying writer, while keeping a copy for replays.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(buf []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+
+	r.body.Write(buf)
+
+	return r.ResponseWriter.Write(buf)
+}
+
+// fingerprintRequest hashes everything determining the outcome of a request, apart from its headers.
+func fingerprintRequest(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+
+	h.Write(body)
+
+	return h.Sum(nil)
+}
+
+func replayResponse(w http.ResponseWriter, key *models.IdempotencyKey) {
+	if key.ContentType != "" {
+		w.Header().Set("Content-Type", key.ContentType)
+	}
+
+	w.Header().Set(IDEMPOTENT_REPLAYED_HEADER, "true")
+	w.WriteHeader(key.StatusCode)
+	w.Write(key.Body)
+}
+
+func completeIdempotencyKey(ctx context.Context, conn *bun.DB, key *models.IdempotencyKey, rec *responseRecorder) {
+	// the response must be stored, even if the client gave up waiting for it
+	ctx = context.WithoutCancel(ctx)
+
+	statusCode := rec.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	// server errors and exhausted rate limits or quotas are transient, replaying them would turn them into permanent failures for the key
+	if statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests {
+		log.Printf("%s failed with %d, releasing it", key, statusCode)
+
+		key.Release(ctx, conn)
+		return
+	}
+
+	if err := key.Complete(ctx, conn, statusCode, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+		log.Printf("%s could not be completed, releasing it: %v", key, err)
+
+		key.Release(ctx, conn)
+	}
+}
+
+// WithIdempotencyKey stores the response of requests carrying an Idempotency-Key header per client
+// and replays it for retries of the same request within the idempotency window.
+// Reusing a key for a different request is rejected, as are retries while the original request is still in progress.
+func WithIdempotencyKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+
+		if value == "" {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		var err error
+
+		var clientId string
+		if clientId, err = auth.HandleBasicAuth(r); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		body := new(bytes.Buffer)
+
+		if _, err = body.ReadFrom(http.MaxBytesReader(w, r.Body, MAX_IDEMPOTENT_REQUEST_SIZE)); err != nil {
+			log.Println(err)
+
+			errors.WriteResponseError(w, errors.NewResponseError(errors.BAD_REQUEST_ERROR, http.StatusBadRequest))
+			return
+		}
+
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
+
+		key := models.NewIdempotencyKey(clientId, value, fingerprintRequest(r, body.Bytes()))
+
+		var conn *bun.DB
+		if conn, err = db.Connect(); err != nil {
+			log.Println(err)
+
+			errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+			return
+		}
+
+		defer conn.Close()
+
+		var existing *models.IdempotencyKey
+		if existing, err = key.Reserve(ctx, conn); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		switch {
+		case existing == nil:
+			rec := &responseRecorder{ResponseWriter: w}
+
+			next(rec, r)
+
+			completeIdempotencyKey(ctx, conn, key, rec)
+		case !existing.Matches(key.Fingerprint):
+			payload := errors.NewErrorResponse(http.StatusUnprocessableEntity, "Idempotency key already used", "the idempotency key was already used for a different request")
+			errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusUnprocessableEntity))
+		case existing.InProgress():
+			payload := errors.NewErrorResponse(http.StatusConflict, "Idempotency key in use", "a request with the same idempotency key is still being processed")
+			errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusConflict))
+		default:
+			log.Printf("replaying response of %s", existing)
+
+			replayResponse(w, existing)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package api_utils
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/db"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/ratelimit"
+	"github.com/uptrace/bun"
+)
+
+// WithRateLimit limits the requests of every client to the given endpoint using a token bucket,
+// configured by the RATE_LIMIT_<ENDPOINT> or RATE_LIMIT env. All responses carry the RateLimit-* headers.
+func WithRateLimit(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := ratelimit.GetRateLimit(endpoint)
+
+		if limit == nil {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		var err error
+
+		var clientId string
+		if clientId, err = auth.HandleBasicAuth(r); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		var conn *bun.DB
+		if ratelimit.UsesPostgres() {
+			if conn, err = db.Connect(); err != nil {
+				log.Println(err)
+
+				errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+				return
+			}
+
+			defer conn.Close()
+		}
+
+		var result *models.RateLimitResult
+		if result, err = ratelimit.Allow(ctx, ratelimit.NewStore(conn), clientId, endpoint, limit); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if !result.Allowed {
+			log.Printf("client: %s exceeded the rate limit of %s on %s", clientId, limit, endpoint)
+
+			errors.WriteResponseError(w, ratelimit.NewRateLimitError(result))
+			return
+		}
+
+		for key, values := range ratelimit.Header(result) {
+			w.Header()[key] = values
+		}
+
+		next(w, r)
+	}
+}
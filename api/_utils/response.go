@@ -0,0 +1,36 @@
+package api_utils
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+type Resource struct {
+	Type       string `json:"type"`
+	Id         string `json:"id"`
+	Attributes any    `json:"attributes,omitempty"`
+}
+
+type Document struct {
+	Data  any               `json:"data"`
+	Meta  any               `json:"meta,omitempty"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+func WriteDocument(w http.ResponseWriter, status int, doc *Document) {
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("encoding JSON:API document failed: %v", err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", utils.JSON_API)
+	w.WriteHeader(status)
+	w.Write(buf)
+}
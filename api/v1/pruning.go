@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+
+	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
+	"github.com/uptrace/bun"
+)
+
+const PRUNING_POLICY_RESOURCE_TYPE = "pruning-policies"
+
+func newPruningPolicyResource(policy *models.PruningPolicy) *api_utils.Resource {
+	return &api_utils.Resource{
+		Type:       PRUNING_POLICY_RESOURCE_TYPE,
+		Id:         policy.ClientId,
+		Attributes: policy,
+	}
+}
+
+func HandlePruning(w http.ResponseWriter, r *http.Request) {
+	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("pruning", handlePruning)(w, r)
+}
+
+func handlePruning(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	clientId, err := auth.HandleBasicAuth(r)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	var conn *bun.DB
+	if conn, err = store.ConnectPostgres(); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+		return
+	}
+
+	defer conn.Close()
+
+	switch r.Method {
+	case http.MethodPut:
+		policy, err := request.ParsePruningPolicyRequest(r, clientId)
+		if err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if err = policy.Save(ctx, conn); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: newPruningPolicyResource(policy)})
+	case http.MethodDelete:
+		if err = models.DeletePruningPolicy(ctx, conn, clientId); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		policy, err := models.GetPruningPolicy(ctx, conn, clientId)
+		if err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if policy == nil {
+			payload := errors.NewErrorResponse(http.StatusNotFound, "No pruning policy configured")
+			errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusNotFound))
+			return
+		}
+
+		api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: newPruningPolicyResource(policy)})
+	}
+}
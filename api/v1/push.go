@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
 
 	"github.com/gorilla/schema"
 	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
@@ -13,12 +16,22 @@ import (
 	"github.com/saschazar21/go-web-push-server/db"
 	"github.com/saschazar21/go-web-push-server/errors"
 	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/provider"
+	"github.com/saschazar21/go-web-push-server/ratelimit"
 	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
 	"github.com/saschazar21/go-web-push-server/utils"
 	"github.com/saschazar21/go-web-push-server/webpush"
 	"github.com/uptrace/bun"
 )
 
+const (
+	PUSH_RESULT_RESOURCE_TYPE  = "push-results"
+	PUSH_DRY_RUN_RESOURCE_TYPE = "push-dry-runs"
+)
+
+const MAX_CONCURRENT_BATCH_DELIVERIES = 10
+
 var decoder = schema.NewDecoder()
 
 func decodePushParams(r *http.Request) (params *request.WebPushDetails, err error) {
@@ -53,50 +66,188 @@ func decodePushRecipient(r *http.Request) (recipientId string, err error) {
 	return
 }
 
-func deleteObsoleteSubscriptions(ctx context.Context, db *bun.DB, errorObjects []errors.ErrorObject) (err error) {
+func isBatchPush(r *http.Request) bool {
+	values, _, err := api_utils.HandleURLRegex(r, "/api/v1/push/(?P<action>batch)$")
+
+	return err == nil && len(values) > 0
+}
+
+func requireJSONContentType(contentType, mode string) (err error) {
+	if contentType != utils.APPLICATION_JSON {
+		header := http.Header{
+			http.CanonicalHeaderKey("accept-post"): []string{utils.APPLICATION_JSON},
+		}
+
+		payload := errors.NewErrorResponse(http.StatusUnsupportedMediaType, "unsupported media type", fmt.Sprintf("%s push messages must be sent as %s", mode, utils.APPLICATION_JSON))
+
+		return errors.NewResponseError(payload, http.StatusUnsupportedMediaType, header)
+	}
+
+	return
+}
+
+func renderTemplatePayload(ctx context.Context, db bun.IDB, clientId string, req *request.TemplatePushRequest) (payloads *request.LocalizedPayloads, err error) {
+	var tpl *models.NotificationTemplate
+
+	if tpl, err = models.GetTemplate(ctx, db, clientId, req.TemplateId, req.Version); err != nil {
+		return
+	}
+
+	payloads = request.NewLocalizedPayloads(req.Default)
+
+	locales := []string{req.Default}
+
+	for locale := range req.Locales {
+		locales = append(locales, locale)
+	}
+
+	for _, locale := range locales {
+		var payload []byte
+
+		if payload, err = tpl.Render(req.VariablesFor(locale)); err != nil {
+			return nil, err
+		}
+
+		payloads.Add(locale, payload)
+	}
+
+	if err = validatePayloadSizes(payloads); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func validatePayloadSizes(payloads *request.LocalizedPayloads) (err error) {
+	for _, payload := range payloads.Payloads {
+		if err = webpush.ValidatePayloadSize(payload); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// openPushStores opens the subscription store selected by the SUBSCRIPTION_STORE env and connects to Postgres,
+// when it stores the subscriptions, the templates of the template mode, or the rate limits and quotas. Otherwise conn is nil.
+func openPushStores(withTemplates bool) (subscriptions store.SubscriptionStore, conn *bun.DB, err error) {
+	switch {
+	case store.UsesPostgres(), withTemplates:
+		if conn, err = store.ConnectPostgres(); err != nil {
+			return
+		}
+
+		return store.NewPostgresStore(conn), conn, nil
+	case ratelimit.UsesPostgres():
+		if conn, err = db.Connect(); err != nil {
+			return
+		}
+	}
+
+	if subscriptions, err = store.Open(); err != nil && conn != nil {
+		conn.Close()
+	}
+
+	return
+}
+
+func getPushSubscriptions(ctx context.Context, subscriptions store.SubscriptionStore, params *request.WebPushDetails, tagExpression models.TagExpression) (subs []*models.PushSubscription, err error) {
+	switch {
+	case tagExpression != nil:
+		return subscriptions.GetByTags(ctx, params.ClientId, params.RecipientId, tagExpression)
+	case params.RecipientId != "":
+		return subscriptions.GetByClientIdAndRecipientId(ctx, params.ClientId, params.RecipientId)
+	default:
+		return subscriptions.GetByClientId(ctx, params.ClientId)
+	}
+}
+
+func deleteObsoleteSubscriptions(ctx context.Context, subscriptions store.SubscriptionStore, errorObjects []errors.ErrorObject) (err error) {
 	for _, errObj := range errorObjects {
 		if errObj.Meta == nil || (errObj.Status != http.StatusGone && errObj.Status != http.StatusNotFound) {
 			continue
 		}
 
-		models.DeleteSubscriptionByEndpoint(ctx, db, errObj.Meta.Endpoint)
+		subscriptions.DeleteByEndpoint(ctx, errObj.Meta.Endpoint)
 	}
 
 	return
 }
 
-func sendPushNotifications(subscriptions []*models.PushSubscription, payload []byte, params *request.WithWebPushParams) (errorObjects []errors.ErrorObject, err error) {
-	var notifications []*webpush.WebPush
-	var statusCode int
+// saveDeliveries records the outcome of the deliveries and prunes repeatedly failing subscriptions according to the client's pruning policy.
+// Just like deleting obsolete subscriptions, failures are only logged, as the push notifications were sent already.
+// The deliveries are only saved alongside subscriptions stored in Postgres.
+func saveDeliveries(ctx context.Context, db *bun.DB, clientId string, deliveries []*models.Delivery) {
+	if !store.UsesPostgres() {
+		return
+	}
 
-	for _, sub := range subscriptions {
-		push := new(webpush.WebPush)
+	if err := models.SaveDeliveries(ctx, db, deliveries); err != nil {
+		log.Println(err)
+		return
+	}
 
-		if push, err = webpush.NewWebPush(sub); err != nil {
-			return
-		}
+	if _, err := models.ApplyPruningPolicy(ctx, db, clientId, deliveries); err != nil {
+		log.Println(err)
+	}
+}
+
+type recipientPushResult struct {
+	Status int                  `json:"status"`
+	Sent   int                  `json:"sent"`
+	Failed int                  `json:"failed"`
+	Errors []errors.ErrorObject `json:"errors,omitempty"`
+}
+
+// newUnsentErrorObject reports a push notification, which could not be sent to the subscription's endpoint at all.
+func newUnsentErrorObject(sub *models.PushSubscription) (errObj errors.ErrorObject) {
+	errObj = errors.NewErrorResponse(http.StatusInternalServerError, "Internal Server Error", "push notification could not be sent").Errors[0]
 
-		notifications = append(notifications, push)
+	if sub.Endpoint != nil {
+		errObj.Meta = &errors.ErrorMeta{
+			Endpoint: string(*sub.Endpoint),
+		}
 	}
 
-	for i, notification := range notifications {
+	return
+}
+
+// deliverPushNotifications sends the push notifications one after another, reporting the outcome of every attempted delivery,
+// and an error object for every delivery, which failed or was rejected by the push service.
+// A subscription, which can't be sent to, e.g. as its endpoint violates the endpoint policy, only fails its own delivery.
+func deliverPushNotifications(subscriptions []*models.PushSubscription, payloads *request.LocalizedPayloads, params *request.WithWebPushParams) (deliveries []*models.Delivery, errorObjects []errors.ErrorObject) {
+	for _, sub := range subscriptions {
 		var errObj errors.ErrorObject
-		var res *http.Response
 
-		log.Printf("sending push notification to recipient: %s of client: %s\n", subscriptions[i].RecipientId, subscriptions[i].ClientId)
+		notification, err := webpush.NewWebPush(sub)
+		if err != nil {
+			log.Printf("preparing push notification for recipient: %s of client: %s failed: %v\n", sub.RecipientId, sub.ClientId, err)
 
-		if res, err = notification.Send(payload, params); err != nil {
-			return
+			errorObjects = append(errorObjects, newUnsentErrorObject(sub))
+			continue
 		}
 
-		switch res.StatusCode {
-		case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		log.Printf("sending push notification to recipient: %s of client: %s\n", sub.RecipientId, sub.ClientId)
+
+		res, err := notification.Send(payloads.Select(sub.Locale), params)
+		if err != nil {
+			log.Printf("sending push notification to recipient: %s of client: %s failed: %v\n", sub.RecipientId, sub.ClientId, err)
+
+			deliveries = append(deliveries, models.NewDelivery(notification.Endpoint, 0))
+			errorObjects = append(errorObjects, newUnsentErrorObject(sub))
 			continue
+		}
+
+		delivery := models.NewDelivery(notification.Endpoint, res.StatusCode)
+		deliveries = append(deliveries, delivery)
+
+		if delivery.Succeeded() {
+			continue
+		}
+
+		switch res.StatusCode {
 		case http.StatusBadRequest:
 			errObj = errors.BAD_REQUEST_ERROR.Errors[0]
-			if statusCode != http.StatusInternalServerError {
-				statusCode = http.StatusBadRequest
-			}
 		case http.StatusNotFound:
 			errObj = errors.NewErrorResponse(http.StatusNotFound, "subscription not found").Errors[0]
 		case http.StatusGone:
@@ -106,13 +257,12 @@ func sendPushNotifications(subscriptions []*models.PushSubscription, payload []b
 			errObj.Detail = fmt.Sprintf("Retry after %s", res.Header.Get("Retry-After"))
 		default:
 			errObj = errors.NewErrorResponse(http.StatusInternalServerError, "Internal Server Error").Errors[0]
-			statusCode = http.StatusInternalServerError
 		}
 
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(res.Body)
 
-		log.Printf("[HTTP %d]: push notification for recipient: %s failed. Reason:\n%s\n", res.StatusCode, subscriptions[i].RecipientId, buf.String())
+		log.Printf("[HTTP %d]: push notification for recipient: %s failed. Reason:\n%s\n", res.StatusCode, sub.RecipientId, buf.String())
 		log.Println(res.Header)
 
 		if errObj.Detail == "" {
@@ -126,22 +276,498 @@ func sendPushNotifications(subscriptions []*models.PushSubscription, payload []b
 		errorObjects = append(errorObjects, errObj)
 	}
 
+	return
+}
+
+// aggregateStatusCode returns the most severe status code of the given errors,
+// internal server errors take precedence over bad requests, followed by the first error's status.
+func aggregateStatusCode(errorObjects []errors.ErrorObject) (statusCode int) {
+	for _, errObj := range errorObjects {
+		switch errObj.Status {
+		case http.StatusInternalServerError:
+			return http.StatusInternalServerError
+		case http.StatusBadRequest:
+			statusCode = http.StatusBadRequest
+		}
+	}
+
+	if statusCode == 0 && len(errorObjects) > 0 {
+		statusCode = errorObjects[0].Status
+	}
+
+	return
+}
+
+func sendPushNotifications(subscriptions []*models.PushSubscription, payloads *request.LocalizedPayloads, params *request.WithWebPushParams) (deliveries []*models.Delivery, errorObjects []errors.ErrorObject, err error) {
+	deliveries, errorObjects = deliverPushNotifications(subscriptions, payloads, params)
+
 	if len(errorObjects) > 0 {
-		if statusCode == 0 {
-			statusCode = errorObjects[0].Status
+		err = errors.NewResponseError(&errors.ErrorResponse{Errors: errorObjects}, aggregateStatusCode(errorObjects))
+	}
+
+	return
+}
+
+func newRecipientPushResult(subscriptions []*models.PushSubscription, errorObjects []errors.ErrorObject) (result *recipientPushResult) {
+	result = &recipientPushResult{Status: http.StatusCreated}
+
+	if len(subscriptions) == 0 {
+		result.Status = http.StatusNotFound
+		result.Errors = errors.NewErrorResponse(http.StatusNotFound, "no subscriptions found").Errors
+
+		return
+	}
+
+	result.Sent = len(subscriptions) - len(errorObjects)
+	result.Failed = len(errorObjects)
+	result.Errors = errorObjects
+
+	if len(errorObjects) > 0 {
+		result.Status = aggregateStatusCode(errorObjects)
+	}
+
+	return
+}
+
+// countDelivered returns the amount of deliveries, which were accepted by the push service.
+func countDelivered(deliveries []*models.Delivery) (delivered int) {
+	for _, delivery := range deliveries {
+		if delivery.Succeeded() {
+			delivered++
+		}
+	}
+
+	return
+}
+
+// recordDeliveries counts the delivered push notifications towards the client's quotas,
+// failures are only logged, since the push notifications have already been sent.
+func recordDeliveries(ctx context.Context, limiter ratelimit.Store, clientId string, delivered int) {
+	if err := ratelimit.AddDeliveries(ctx, limiter, clientId, delivered); err != nil {
+		log.Printf("recording %d deliveries of client: %s failed: %v", delivered, clientId, err)
+	}
+}
+
+// pushResultsStatus returns 201 if all push notifications were delivered, otherwise 207.
+func pushResultsStatus(results []*recipientPushResult) int {
+	for _, result := range results {
+		if result.Status != http.StatusCreated {
+			return http.StatusMultiStatus
+		}
+	}
+
+	return http.StatusCreated
+}
+
+func groupSubscriptionsByRecipient(subscriptions []*models.PushSubscription) (subscriptionsByRecipient map[string][]*models.PushSubscription) {
+	subscriptionsByRecipient = make(map[string][]*models.PushSubscription)
+
+	for _, sub := range subscriptions {
+		subscriptionsByRecipient[sub.RecipientId] = append(subscriptionsByRecipient[sub.RecipientId], sub)
+	}
+
+	return
+}
+
+// sendRecipientPushNotifications fans out the push notifications to all subscriptions of the given recipients
+// and reports the results per recipient, recipients without any subscriptions are reported as not found.
+func sendRecipientPushNotifications(recipientIds []string, subscriptions []*models.PushSubscription, payloads *request.LocalizedPayloads, params *request.WithWebPushParams) (results []*recipientPushResult, deliveries []*models.Delivery, errorObjects []errors.ErrorObject) {
+	subscriptionsByRecipient := groupSubscriptionsByRecipient(subscriptions)
+
+	results = make([]*recipientPushResult, 0, len(recipientIds))
+
+	for _, recipientId := range recipientIds {
+		var delivered []*models.Delivery
+		var errs []errors.ErrorObject
+		subs := subscriptionsByRecipient[recipientId]
+
+		if len(subs) > 0 {
+			delivered, errs = deliverPushNotifications(subs, payloads, params)
+			deliveries = append(deliveries, delivered...)
+			errorObjects = append(errorObjects, errs...)
+		}
+
+		results = append(results, newRecipientPushResult(subs, errs))
+	}
+
+	return
+}
+
+func handleRecipientsPush(ctx context.Context, w http.ResponseWriter, subscriptions store.SubscriptionStore, conn *bun.DB, limiter ratelimit.Store, params *request.WebPushDetails, recipientIds []string, payloads *request.LocalizedPayloads) {
+	subs, err := subscriptions.GetByClientIdAndRecipientIds(ctx, params.ClientId, recipientIds)
+	if err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if params.DryRun {
+		dryRun := newPushDryRun()
+		dryRun.UnknownRecipientIds = unknownRecipientIds(recipientIds, subs)
+
+		if err = dryRun.addAll(subs, payloads); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		writePushDryRun(w, params.ClientId, dryRun)
+		return
+	}
+
+	if err = ratelimit.CheckQuota(ctx, limiter, params.ClientId, len(subs)); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	results, deliveries, errorObjects := sendRecipientPushNotifications(recipientIds, subs, payloads, params.WithWebPushParams)
+
+	recordDeliveries(ctx, limiter, params.ClientId, countDelivered(deliveries))
+	saveDeliveries(ctx, conn, params.ClientId, deliveries)
+	deleteObsoleteSubscriptions(ctx, subscriptions, errorObjects)
+
+	resources := make([]*api_utils.Resource, 0, len(results))
+
+	for i, result := range results {
+		resources = append(resources, &api_utils.Resource{
+			Type:       PUSH_RESULT_RESOURCE_TYPE,
+			Id:         recipientIds[i],
+			Attributes: result,
+		})
+	}
+
+	api_utils.WriteDocument(w, pushResultsStatus(results), &api_utils.Document{Data: resources})
+}
+
+type pushServiceDryRun struct {
+	Targets        int `json:"targets"`
+	EncryptedBytes int `json:"encryptedBytes"`
+}
+
+// pushDryRun summarizes the push notifications, which would have been sent without the dryRun parameter.
+type pushDryRun struct {
+	Targets             int                           `json:"targets"`
+	RecipientIds        []string                      `json:"recipientIds"`
+	UnknownRecipientIds []string                      `json:"unknownRecipientIds,omitempty"`
+	MaxEncryptedSize    int                           `json:"maxEncryptedSize"`
+	PushServices        map[string]*pushServiceDryRun `json:"pushServices"`
+
+	recipients map[string]bool
+}
+
+func newPushDryRun() *pushDryRun {
+	return &pushDryRun{
+		RecipientIds: []string{},
+		PushServices: map[string]*pushServiceDryRun{},
+		recipients:   map[string]bool{},
+	}
+}
+
+// add encrypts the payload for the subscription, exactly as it would have been sent.
+func (d *pushDryRun) add(sub *models.PushSubscription, payload []byte) (err error) {
+	var push *webpush.WebPush
+
+	if push, err = webpush.NewWebPush(sub); err != nil {
+		return
+	}
+
+	var buf []byte
+
+	if buf, err = push.Encrypt(payload); err != nil {
+		return
+	}
+
+	service := provider.ForEndpoint(push.Endpoint).Name
+
+	if _, ok := d.PushServices[service]; !ok {
+		d.PushServices[service] = &pushServiceDryRun{}
+	}
+
+	d.PushServices[service].Targets++
+	d.PushServices[service].EncryptedBytes += len(buf)
+
+	d.Targets++
+	d.MaxEncryptedSize = max(d.MaxEncryptedSize, len(buf))
+
+	if !d.recipients[sub.RecipientId] {
+		d.recipients[sub.RecipientId] = true
+		d.RecipientIds = append(d.RecipientIds, sub.RecipientId)
+	}
+
+	return
+}
+
+func (d *pushDryRun) addAll(subscriptions []*models.PushSubscription, payloads *request.LocalizedPayloads) (err error) {
+	for _, sub := range subscriptions {
+		if err = d.add(sub, payloads.Select(sub.Locale)); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func unknownRecipientIds(recipientIds []string, subscriptions []*models.PushSubscription) (unknown []string) {
+	subscriptionsByRecipient := groupSubscriptionsByRecipient(subscriptions)
+
+	for _, recipientId := range recipientIds {
+		if len(subscriptionsByRecipient[recipientId]) == 0 {
+			unknown = append(unknown, recipientId)
+		}
+	}
+
+	return
+}
+
+func writePushDryRun(w http.ResponseWriter, clientId string, dryRun *pushDryRun) {
+	slices.Sort(dryRun.RecipientIds)
+
+	resource := &api_utils.Resource{
+		Type:       PUSH_DRY_RUN_RESOURCE_TYPE,
+		Id:         clientId,
+		Attributes: dryRun,
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: resource})
+}
+
+type batchPushResult struct {
+	RecipientId string `json:"recipientId"`
+
+	*recipientPushResult
+}
+
+func newBatchPayloads(batch *request.BatchPushRequest, mode string) (payloads []*request.LocalizedPayloads, err error) {
+	payloads = make([]*request.LocalizedPayloads, 0, len(batch.Entries))
+
+	for i, entry := range batch.Entries {
+		payload := entry.RawPayload()
+
+		if mode == utils.PUSH_MODE_DECLARATIVE {
+			if payload, err = webpush.NewDeclarativePayloadFromRequest(entry.Payload); err != nil {
+				log.Printf("invalid declarative payload in batch entry %d: %v", i, err)
+				return nil, err
+			}
 		}
 
-		err = errors.NewResponseError(&errors.ErrorResponse{Errors: errorObjects}, statusCode)
+		if err = webpush.ValidatePayloadSize(payload); err != nil {
+			log.Printf("payload of batch entry %d is too large", i)
+			return nil, err
+		}
+
+		payloads = append(payloads, request.NewPayload(payload))
+	}
+
+	return
+}
+
+// countBatchTargets returns the amount of push notifications a batch fans out to,
+// i.e. the subscriptions of every entry, including entries repeating a recipient.
+func countBatchTargets(batch *request.BatchPushRequest, subscriptions []*models.PushSubscription) (targets int) {
+	subscriptionsByRecipient := groupSubscriptionsByRecipient(subscriptions)
+
+	for _, entry := range batch.Entries {
+		targets += len(subscriptionsByRecipient[entry.RecipientId])
 	}
 
 	return
 }
 
+// sendBatchPushNotifications delivers every batch entry to the subscriptions of its recipient concurrently,
+// limited to MAX_CONCURRENT_BATCH_DELIVERIES entries at a time, and reports the results in the order of the entries.
+func sendBatchPushNotifications(batch *request.BatchPushRequest, subscriptions []*models.PushSubscription, payloads []*request.LocalizedPayloads, params *request.WithWebPushParams) (results []*recipientPushResult, deliveries []*models.Delivery, errorObjects []errors.ErrorObject) {
+	subscriptionsByRecipient := groupSubscriptionsByRecipient(subscriptions)
+
+	results = make([]*recipientPushResult, len(batch.Entries))
+	delivered := make([][]*models.Delivery, len(batch.Entries))
+	errs := make([][]errors.ErrorObject, len(batch.Entries))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, MAX_CONCURRENT_BATCH_DELIVERIES)
+
+	for i, entry := range batch.Entries {
+		subs := subscriptionsByRecipient[entry.RecipientId]
+
+		if len(subs) == 0 {
+			results[i] = newRecipientPushResult(subs, nil)
+			continue
+		}
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int, entry *request.BatchPushEntry, subs []*models.PushSubscription) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			delivered[i], errs[i] = deliverPushNotifications(subs, payloads[i], entry.Params(params))
+			results[i] = newRecipientPushResult(subs, errs[i])
+		}(i, entry, subs)
+	}
+
+	wg.Wait()
+
+	for i := range batch.Entries {
+		deliveries = append(deliveries, delivered[i]...)
+		errorObjects = append(errorObjects, errs[i]...)
+	}
+
+	return
+}
+
+func handleBatchPush(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var err error
+
+	var clientId string
+	if clientId, err = auth.HandleBasicAuth(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): []string{http.MethodPost},
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	var params *request.WebPushDetails
+	if params, err = decodePushParams(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	params.ClientId = clientId
+
+	if err = params.Validate(); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if params.Mode != "" && params.Mode != utils.PUSH_MODE_RAW && params.Mode != utils.PUSH_MODE_DECLARATIVE {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid webpush parameters", fmt.Sprintf("batch push messages do not support the %s mode", params.Mode))
+		errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusBadRequest))
+		return
+	}
+
+	if params.Tags != "" || params.Target != "" {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid webpush parameters", "batch push messages must not be combined with tags or another target")
+		errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusBadRequest))
+		return
+	}
+
+	if err = requireJSONContentType(r.Header.Get("Content-Type"), "batch"); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+
+	if _, err = buf.ReadFrom(r.Body); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.BAD_REQUEST_ERROR, http.StatusBadRequest))
+		return
+	}
+
+	var batch *request.BatchPushRequest
+	if batch, err = request.ParseBatchPushRequest(buf.Bytes()); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	var payloads []*request.LocalizedPayloads
+	if payloads, err = newBatchPayloads(batch, params.Mode); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	var subscriptions store.SubscriptionStore
+	var conn *bun.DB
+	if subscriptions, conn, err = openPushStores(false); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+		return
+	}
+
+	defer subscriptions.Close()
+
+	if conn != nil {
+		defer conn.Close()
+	}
+
+	limiter := ratelimit.NewStore(conn)
+
+	var subs []*models.PushSubscription
+	if subs, err = subscriptions.GetByClientIdAndRecipientIds(ctx, params.ClientId, batch.RecipientIds()); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if params.DryRun {
+		dryRun := newPushDryRun()
+		dryRun.UnknownRecipientIds = unknownRecipientIds(batch.RecipientIds(), subs)
+		subscriptionsByRecipient := groupSubscriptionsByRecipient(subs)
+
+		for i, entry := range batch.Entries {
+			if err = dryRun.addAll(subscriptionsByRecipient[entry.RecipientId], payloads[i]); err != nil {
+				errors.WriteResponseError(w, err)
+				return
+			}
+		}
+
+		writePushDryRun(w, params.ClientId, dryRun)
+		return
+	}
+
+	if err = ratelimit.CheckQuota(ctx, limiter, params.ClientId, countBatchTargets(batch, subs)); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	results, deliveries, errorObjects := sendBatchPushNotifications(batch, subs, payloads, params.WithWebPushParams)
+
+	recordDeliveries(ctx, limiter, params.ClientId, countDelivered(deliveries))
+	saveDeliveries(ctx, conn, params.ClientId, deliveries)
+	deleteObsoleteSubscriptions(ctx, subscriptions, errorObjects)
+
+	resources := make([]*api_utils.Resource, 0, len(results))
+
+	for i, result := range results {
+		resources = append(resources, &api_utils.Resource{
+			Type: PUSH_RESULT_RESOURCE_TYPE,
+			Id:   strconv.Itoa(i),
+			Attributes: &batchPushResult{
+				RecipientId:         batch.Entries[i].RecipientId,
+				recipientPushResult: result,
+			},
+		})
+	}
+
+	api_utils.WriteDocument(w, pushResultsStatus(results), &api_utils.Document{Data: resources})
+}
+
 func HandlePush(w http.ResponseWriter, r *http.Request) {
 	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("push", api_utils.WithIdempotencyKey(handlePush))(w, r)
+}
+
+func handlePush(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var err error
 
+	if isBatchPush(r) {
+		handleBatchPush(w, r)
+		return
+	}
+
 	var recipientId string
 	if recipientId, err = decodePushRecipient(r); err != nil {
 		errors.WriteResponseError(w, err)
@@ -193,6 +819,20 @@ func HandlePush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if params.Target == utils.PUSH_TARGET_RECIPIENTS && (params.RecipientId != "" || params.Tags != "") {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid webpush parameters", "recipients targeting must not be combined with a recipient ID or tags")
+		errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusBadRequest))
+		return
+	}
+
+	var tagExpression models.TagExpression
+	if params.Tags != "" {
+		if tagExpression, err = models.ParseTagExpression(params.Tags); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+	}
+
 	buf := new(bytes.Buffer)
 
 	if _, err = buf.ReadFrom(r.Body); err != nil {
@@ -208,35 +848,108 @@ func HandlePush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body := buf.Bytes()
+
+	var recipientsRequest *request.RecipientsPushRequest
+
+	if params.Target == utils.PUSH_TARGET_RECIPIENTS {
+		if err = requireJSONContentType(contentType, params.Target); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if recipientsRequest, err = request.ParseRecipientsPushRequest(body); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		body = recipientsRequest.Payload
+	}
+
+	payloads := request.NewPayload(body)
+
+	if recipientsRequest != nil && (params.Mode == "" || params.Mode == utils.PUSH_MODE_RAW) {
+		payloads = request.NewPayload(recipientsRequest.RawPayload())
+	}
+
+	var templateRequest *request.TemplatePushRequest
+
+	switch params.Mode {
+	case utils.PUSH_MODE_DECLARATIVE:
+		if err = requireJSONContentType(contentType, params.Mode); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		var payload []byte
+		if payload, err = webpush.NewDeclarativePayloadFromRequest(body); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		payloads = request.NewPayload(payload)
+	case utils.PUSH_MODE_LOCALIZED:
+		if err = requireJSONContentType(contentType, params.Mode); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if payloads, err = request.ParseLocalizedPushRequest(body); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if err = validatePayloadSizes(payloads); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+	case utils.PUSH_MODE_TEMPLATE:
+		if err = requireJSONContentType(contentType, params.Mode); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if templateRequest, err = request.ParseTemplatePushRequest(body); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+	}
+
+	var subscriptions store.SubscriptionStore
 	var conn *bun.DB
-	if conn, err = db.Connect(); err != nil {
+	if subscriptions, conn, err = openPushStores(templateRequest != nil); err != nil {
 		log.Println(err)
 
 		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
 		return
 	}
 
-	defer conn.Close()
+	defer subscriptions.Close()
 
-	var subs []*models.PushSubscription
-	if params.RecipientId != "" {
-		subs, err = models.GetSubscriptionsByClientIdAndRecipientId(ctx, conn, params.ClientId, params.RecipientId)
+	if conn != nil {
+		defer conn.Close()
+	}
 
-		if err != nil {
-			log.Println(err)
+	limiter := ratelimit.NewStore(conn)
 
+	if templateRequest != nil {
+		if payloads, err = renderTemplatePayload(ctx, conn, params.ClientId, templateRequest); err != nil {
 			errors.WriteResponseError(w, err)
 			return
 		}
-	} else {
-		subs, err = models.GetSubscriptionsByClientId(ctx, conn, params.ClientId)
+	}
 
-		if err != nil {
-			log.Println(err)
+	if recipientsRequest != nil {
+		handleRecipientsPush(ctx, w, subscriptions, conn, limiter, params, recipientsRequest.RecipientIds, payloads)
+		return
+	}
 
-			errors.WriteResponseError(w, err)
-			return
-		}
+	var subs []*models.PushSubscription
+	if subs, err = getPushSubscriptions(ctx, subscriptions, params, tagExpression); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, err)
+		return
 	}
 
 	if len(subs) == 0 {
@@ -245,10 +958,32 @@ func HandlePush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if errorObjects, err := sendPushNotifications(subs, buf.Bytes(), params.WithWebPushParams); err != nil {
+	if params.DryRun {
+		dryRun := newPushDryRun()
+
+		if err = dryRun.addAll(subs, payloads); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		writePushDryRun(w, params.ClientId, dryRun)
+		return
+	}
+
+	if err = ratelimit.CheckQuota(ctx, limiter, params.ClientId, len(subs)); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	deliveries, errorObjects, err := sendPushNotifications(subs, payloads, params.WithWebPushParams)
+
+	recordDeliveries(ctx, limiter, params.ClientId, countDelivered(deliveries))
+	saveDeliveries(ctx, conn, params.ClientId, deliveries)
+
+	if err != nil {
 		log.Println(err)
 
-		deleteObsoleteSubscriptions(ctx, conn, errorObjects)
+		deleteObsoleteSubscriptions(ctx, subscriptions, errorObjects)
 
 		errors.WriteResponseError(w, err)
 		return
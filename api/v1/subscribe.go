@@ -1,19 +1,46 @@
 package v1
 
 import (
-	"database/sql"
 	"log"
 	"net/http"
 
+	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
 	"github.com/saschazar21/go-web-push-server/auth"
-	"github.com/saschazar21/go-web-push-server/db"
 	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
 	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
 	"github.com/uptrace/bun"
 )
 
 func HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("subscribe", handleSubscribe)(w, r)
+}
+
+func isSubscriptionChange(r *http.Request) (ok bool, err error) {
+	var values []string
+
+	values, _, err = api_utils.HandleURLRegex(r, "/api/v1/subscribe/(?P<change>change)$")
+	ok = err == nil && len(values) > 0
+
+	return
+}
+
+func handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	isChange, err := isSubscriptionChange(r)
+
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if isChange {
+		handleSubscriptionChange(w, r)
+		return
+	}
+
 	clientId, err := auth.HandleBasicAuth(r)
 
 	if err != nil {
@@ -35,8 +62,8 @@ func HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var conn *bun.DB
-	conn, err = db.Connect()
+	var s store.SubscriptionStore
+	s, err = store.Open()
 
 	if err != nil {
 		log.Println(err)
@@ -45,12 +72,36 @@ func HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	defer conn.Close()
+	defer s.Close()
 
-	ctx := r.Context()
+	if err = s.Save(r.Context(), sub); err != nil {
+		log.Println(err)
 
-	var tx bun.Tx
-	tx, err = conn.BeginTx(ctx, &sql.TxOptions{})
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func handleSubscriptionChange(w http.ResponseWriter, r *http.Request) {
+	clientId, err := auth.HandleBasicAuth(r)
+
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): []string{http.MethodPost},
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	change, err := request.ParseSubscriptionChangeRequest(r)
 
 	if err != nil {
 		log.Println(err)
@@ -59,19 +110,27 @@ func HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = sub.Save(ctx, tx); err != nil {
+	if clientId != change.ClientId {
+		errors.WriteResponseError(w, errors.NewResponseError(auth.FORBIDDEN_ERROR, http.StatusBadRequest))
+		return
+	}
+
+	var conn *bun.DB
+	conn, err = store.ConnectPostgres()
+
+	if err != nil {
 		log.Println(err)
 
-		errors.WriteResponseError(w, err)
-		tx.Rollback()
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
 		return
 	}
 
-	if err = tx.Commit(); err != nil {
+	defer conn.Close()
+
+	if err = models.ReplaceSubscription(r.Context(), conn, change.ClientId, change.OldHashes, change.OldAuthSecret, change.Subscription); err != nil {
 		log.Println(err)
 
 		errors.WriteResponseError(w, err)
-		tx.Rollback()
 		return
 	}
 
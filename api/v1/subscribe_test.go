@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/store"
 	webpush_test "github.com/saschazar21/go-web-push-server/test"
 	"github.com/saschazar21/go-web-push-server/utils"
 	"gotest.tools/v3/assert"
@@ -18,6 +20,7 @@ func TestHandleSubscribe(t *testing.T) {
 	basicAuthPassword := "123"
 	t.Setenv(auth.BASIC_AUTH_PASSWORD_ENV, basicAuthPassword)
 	t.Setenv("CWD", "../../")
+	t.Setenv(utils.ENDPOINT_ALLOW_UNKNOWN_PUSH_SERVICES_ENV, "true")
 
 	type keys struct {
 		P256DH string `json:"p256dh"`
@@ -215,3 +218,65 @@ func TestRawHandleSubscribe(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleSubscribeMemoryStore(t *testing.T) {
+	basicAuthPassword := "123"
+	t.Setenv(auth.BASIC_AUTH_PASSWORD_ENV, basicAuthPassword)
+	t.Setenv("CWD", "../../")
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+	t.Setenv(utils.SUBSCRIPTION_STORE_ENV, store.STORE_MEMORY)
+
+	payload := `{
+		"clientId": "memory subscribe client",
+		"id": "test user",
+		"subscription": {
+			"endpoint": "https://fcm.googleapis.com/fcm/send/memory-subscribe",
+			"keys": {
+				"p256dh": "BDUNJTPVQKJSqsXKIf68MiXwxattZqUr_oVOYAxq4Yk1F7pdSsOTixT9SUyvnYQAD7Sgj7laojLUzI3jZoAT9jM",
+				"auth": "DfAUjwU7hg-vHYn68BropQ"
+			}
+		}
+	}`
+
+	server := httptest.NewServer(http.HandlerFunc(HandleSubscribe))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(payload))
+	req.Header.Add("content-type", utils.APPLICATION_JSON)
+	req.SetBasicAuth("memory subscribe client", basicAuthPassword)
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusCreated)
+
+	s, err := store.Open()
+	assert.NilError(t, err)
+
+	subscriptions, err := s.GetByClientIdAndRecipientId(context.Background(), "memory subscribe client", "test user")
+	assert.NilError(t, err)
+	assert.Equal(t, len(subscriptions), 1)
+	assert.Equal(t, string(*subscriptions[0].Endpoint), "https://fcm.googleapis.com/fcm/send/memory-subscribe")
+}
+
+func TestHandleSubscriptionChangeMethod(t *testing.T) {
+	basicAuthPassword := "123"
+	t.Setenv(auth.BASIC_AUTH_PASSWORD_ENV, basicAuthPassword)
+
+	server := httptest.NewServer(http.HandlerFunc(HandleSubscribe))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/api/v1/subscribe/change", nil)
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusUnauthorized)
+
+	req, _ = http.NewRequest(http.MethodPut, server.URL+"/api/v1/subscribe/change", nil)
+	req.SetBasicAuth("test client", basicAuthPassword)
+
+	res, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, res.StatusCode, http.StatusMethodNotAllowed)
+	assert.Equal(t, res.Header.Get("Allow"), http.MethodPost)
+}
@@ -0,0 +1,216 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const SUBSCRIPTIONS_RESOURCE_TYPE = "subscriptions"
+
+// subscriptionAttributes deliberately omit the endpoint and keys of a subscription.
+type subscriptionAttributes struct {
+	RecipientId    string             `json:"recipientId"`
+	Locale         string             `json:"locale"`
+	PushService    string             `json:"pushService"`
+	Tags           []string           `json:"tags"`
+	ExpirationTime *utils.EpochMillis `json:"expirationTime,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	LastFailureAt       *time.Time `json:"lastFailureAt"`
+	LastStatusCode      *int       `json:"lastStatusCode"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	QuarantinedAt       *time.Time `json:"quarantinedAt"`
+}
+
+func utcOrNil(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	utc := t.UTC()
+
+	return &utc
+}
+
+func newSubscriptionResource(sub *models.PushSubscription) *api_utils.Resource {
+	tags := sub.Tags
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	var lastStatusCode *int
+
+	if sub.LastStatusCode != 0 {
+		lastStatusCode = &sub.LastStatusCode
+	}
+
+	return &api_utils.Resource{
+		Type: SUBSCRIPTIONS_RESOURCE_TYPE,
+		Id:   sub.Hash.String(),
+		Attributes: &subscriptionAttributes{
+			RecipientId:    sub.RecipientId,
+			Locale:         sub.Locale,
+			PushService:    sub.PushService,
+			Tags:           tags,
+			ExpirationTime: sub.ExpirationTime,
+			CreatedAt:      sub.CreatedAt.UTC(),
+			UpdatedAt:      sub.UpdatedAt.UTC(),
+
+			LastSuccessAt:       utcOrNil(sub.LastSuccessAt),
+			LastFailureAt:       utcOrNil(sub.LastFailureAt),
+			LastStatusCode:      lastStatusCode,
+			ConsecutiveFailures: sub.ConsecutiveFailures,
+			QuarantinedAt:       utcOrNil(sub.QuarantinedAt),
+		},
+	}
+}
+
+// decodeSubscriptionHash returns the hash of /api/v1/subscriptions/{hash}, and whether /api/v1/subscriptions/{hash}/restore was requested.
+func decodeSubscriptionHash(r *http.Request) (hash string, restore bool, err error) {
+	var names []string
+	var values []string
+
+	if values, names, err = api_utils.HandleURLRegex(r, "/api/v1/subscriptions/(?P<hash>[^/]+?)(?P<restore>/restore)?$"); err != nil || len(values) == 0 {
+		return
+	}
+
+	for i, name := range names {
+		switch name {
+		case "hash":
+			hash = values[i]
+		case "restore":
+			restore = values[i] != ""
+		}
+	}
+
+	return
+}
+
+// subscriptionsLinks returns the self link, and the next link, if there is a next page.
+func subscriptionsLinks(r *http.Request, next *models.SubscriptionCursor) (links map[string]string) {
+	self := url.URL{Path: "/api/v1/subscriptions", RawQuery: r.URL.RawQuery}
+
+	links = map[string]string{"self": self.String()}
+
+	if next != nil {
+		query := r.URL.Query()
+		query.Set("cursor", next.String())
+
+		links["next"] = (&url.URL{Path: self.Path, RawQuery: query.Encode()}).String()
+	}
+
+	return
+}
+
+func HandleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("subscriptions", handleSubscriptions)(w, r)
+}
+
+func handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	clientId, err := auth.HandleBasicAuth(r)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	var hash string
+	var restore bool
+	if hash, restore, err = decodeSubscriptionHash(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	allowed := http.MethodGet
+
+	if restore {
+		allowed = http.MethodPost
+	}
+
+	if r.Method != allowed {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): []string{allowed},
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	var filter *models.SubscriptionFilter
+
+	if hash == "" {
+		query := &request.SubscriptionsQuery{}
+
+		if err = api_utils.DecodeQueryParams(r, query); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		if filter, err = query.Filter(); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+	}
+
+	var conn *bun.DB
+	if conn, err = store.ConnectPostgres(); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+		return
+	}
+
+	defer conn.Close()
+
+	if hash != "" {
+		var sub *models.PushSubscription
+
+		if restore {
+			sub, err = models.RestoreSubscription(ctx, conn, clientId, hash)
+		} else {
+			sub, err = models.GetSubscriptionByClientIdAndHash(ctx, conn, clientId, hash)
+		}
+
+		if err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: newSubscriptionResource(sub)})
+		return
+	}
+
+	subs, next, err := models.ListSubscriptions(ctx, conn, clientId, filter)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	resources := make([]*api_utils.Resource, 0, len(subs))
+
+	for _, sub := range subs {
+		resources = append(resources, newSubscriptionResource(sub))
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{
+		Data:  resources,
+		Links: subscriptionsLinks(r, next),
+	})
+}
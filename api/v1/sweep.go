@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/store"
+)
+
+const SWEEPS_RESOURCE_TYPE = "sweeps"
+
+// HandleSweep deletes expired subscriptions and orphaned keys, it is meant to be invoked by a scheduler, e.g. Vercel Cron Jobs.
+func HandleSweep(w http.ResponseWriter, r *http.Request) {
+	log.Println(r.URL.String())
+
+	if err := auth.HandleCronAuth(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): []string{http.MethodGet, http.MethodPost},
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	conn, err := store.ConnectPostgres()
+
+	if err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	defer conn.Close()
+
+	result, err := models.Sweep(r.Context(), conn, models.GetSweepGracePeriod())
+
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{
+		Data: &api_utils.Resource{
+			Type:       SWEEPS_RESOURCE_TYPE,
+			Id:         time.Now().UTC().Format(time.RFC3339),
+			Attributes: result,
+		},
+	})
+}
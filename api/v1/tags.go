@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"log"
+	"net/http"
+
+	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
+	"github.com/uptrace/bun"
+)
+
+const TAGS_RESOURCE_TYPE = "tags"
+
+type recipientTags struct {
+	Tags []string `json:"tags"`
+}
+
+func decodeTagsRecipient(r *http.Request) (recipientId string, err error) {
+	var names []string
+	var values []string
+
+	if values, names, err = api_utils.HandleURLRegex(r, "/api/v1/tags/(?P<id>[^/]+)$"); err != nil || len(values) == 0 {
+		return
+	}
+
+	for i, name := range names {
+		if name == "id" {
+			recipientId = values[i]
+			break
+		}
+	}
+
+	return
+}
+
+func HandleTags(w http.ResponseWriter, r *http.Request) {
+	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("tags", handleTags)(w, r)
+}
+
+func handleTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var err error
+
+	var recipientId string
+	if recipientId, err = decodeTagsRecipient(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if recipientId == "" {
+		recipientParams, err := api_utils.DecodeRecipientParams(r)
+
+		if err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		recipientId = recipientParams.RecipientId
+	}
+
+	var clientId string
+	if clientId, err = auth.HandleBasicAuth(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): []string{http.MethodGet, http.MethodPut, http.MethodPatch},
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	if recipientId == "" {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "missing recipient ID")
+		errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusBadRequest))
+		return
+	}
+
+	var conn *bun.DB
+	if conn, err = store.ConnectPostgres(); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+		return
+	}
+
+	defer conn.Close()
+
+	switch r.Method {
+	case http.MethodPut:
+		var req *request.TagsRequest
+		if req, err = request.ParseTagsRequest(r); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		err = models.SetTagsByClientIdAndRecipientId(ctx, conn, clientId, recipientId, req.Tags)
+	case http.MethodPatch:
+		var req *request.TagsUpdateRequest
+		if req, err = request.ParseTagsUpdateRequest(r); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		err = models.UpdateTagsByClientIdAndRecipientId(ctx, conn, clientId, recipientId, req.Add, req.Remove)
+	}
+
+	if err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	var tags []string
+	if tags, err = models.GetTagsByClientIdAndRecipientId(ctx, conn, clientId, recipientId); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	resource := &api_utils.Resource{
+		Type:       TAGS_RESOURCE_TYPE,
+		Id:         recipientId,
+		Attributes: &recipientTags{Tags: tags},
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: resource})
+}
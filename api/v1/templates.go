@@ -0,0 +1,247 @@
+package v1
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
+	"github.com/saschazar21/go-web-push-server/auth"
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
+	"github.com/saschazar21/go-web-push-server/webpush"
+	"github.com/uptrace/bun"
+)
+
+const (
+	TEMPLATE_RESOURCE_TYPE         = "templates"
+	TEMPLATE_PREVIEW_RESOURCE_TYPE = "template-previews"
+)
+
+type templateParams struct {
+	Version int `schema:"version"`
+}
+
+type templatePreview struct {
+	Version int             `json:"version"`
+	Size    int             `json:"size"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func decodeTemplatePath(r *http.Request) (templateId, action string, err error) {
+	var names []string
+	var values []string
+
+	if values, names, err = api_utils.HandleURLRegex(r, "/api/v1/templates/(?P<id>[^/]+)(?:/(?P<action>preview|versions))?$"); err != nil || len(values) == 0 {
+		return
+	}
+
+	for i, name := range names {
+		switch name {
+		case "id":
+			templateId = values[i]
+		case "action":
+			action = values[i]
+		}
+	}
+
+	return
+}
+
+func decodeTemplateParams(r *http.Request) (params *templateParams, err error) {
+	params = &templateParams{}
+
+	decoder.IgnoreUnknownKeys(true)
+	if err = decoder.Decode(params, r.URL.Query()); err != nil {
+		log.Println(err)
+
+		err = errors.NewResponseError(errors.BAD_REQUEST_ERROR, http.StatusBadRequest)
+		return
+	}
+
+	if params.Version < 0 {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid template version")
+		err = errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func newTemplateResource(tpl *models.NotificationTemplate) *api_utils.Resource {
+	return &api_utils.Resource{
+		Type:       TEMPLATE_RESOURCE_TYPE,
+		Id:         tpl.TemplateId,
+		Attributes: tpl,
+	}
+}
+
+func createTemplate(w http.ResponseWriter, r *http.Request, conn bun.IDB, clientId string) {
+	tpl, err := request.ParseTemplateRequest(r, clientId)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if err = tpl.Save(r.Context(), conn); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	api_utils.WriteDocument(w, http.StatusCreated, &api_utils.Document{Data: newTemplateResource(tpl)})
+}
+
+func getTemplate(w http.ResponseWriter, r *http.Request, conn bun.IDB, clientId, templateId string) {
+	params, err := decodeTemplateParams(r)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	tpl, err := models.GetTemplate(r.Context(), conn, clientId, templateId, params.Version)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: newTemplateResource(tpl)})
+}
+
+func getTemplateVersions(w http.ResponseWriter, r *http.Request, conn bun.IDB, clientId, templateId string) {
+	templates, err := models.GetTemplateVersions(r.Context(), conn, clientId, templateId)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if len(templates) == 0 {
+		payload := errors.NewErrorResponse(http.StatusNotFound, "Notification template not found")
+		errors.WriteResponseError(w, errors.NewResponseError(payload, http.StatusNotFound))
+		return
+	}
+
+	resources := make([]*api_utils.Resource, len(templates))
+
+	for i, tpl := range templates {
+		resources[i] = newTemplateResource(tpl)
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: resources})
+}
+
+func previewTemplate(w http.ResponseWriter, r *http.Request, conn bun.IDB, clientId, templateId string) {
+	req, err := request.ParseTemplatePreviewRequest(r, templateId)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	tpl, err := models.GetTemplate(r.Context(), conn, clientId, req.TemplateId, req.Version)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	payload, err := tpl.Render(req.Variables)
+	if err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	if err = webpush.ValidatePayloadSize(payload); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	resource := &api_utils.Resource{
+		Type: TEMPLATE_PREVIEW_RESOURCE_TYPE,
+		Id:   tpl.TemplateId,
+		Attributes: &templatePreview{
+			Version: tpl.Version,
+			Size:    len(payload),
+			Payload: payload,
+		},
+	}
+
+	api_utils.WriteDocument(w, http.StatusOK, &api_utils.Document{Data: resource})
+}
+
+func HandleTemplates(w http.ResponseWriter, r *http.Request) {
+	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("templates", handleTemplates)(w, r)
+}
+
+func handleTemplates(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	var templateId, action string
+	if templateId, action, err = decodeTemplatePath(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	var clientId string
+	if clientId, err = auth.HandleBasicAuth(r); err != nil {
+		errors.WriteResponseError(w, err)
+		return
+	}
+
+	allowed := []string{http.MethodGet, http.MethodDelete}
+
+	switch {
+	case templateId == "":
+		allowed = []string{http.MethodPost}
+	case action == "preview":
+		allowed = []string{http.MethodPost}
+	case action == "versions":
+		allowed = []string{http.MethodGet}
+	}
+
+	isAllowed := false
+
+	for _, method := range allowed {
+		if r.Method == method {
+			isAllowed = true
+			break
+		}
+	}
+
+	if !isAllowed {
+		header := http.Header{
+			http.CanonicalHeaderKey("allow"): allowed,
+		}
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, header))
+		return
+	}
+
+	var conn *bun.DB
+	if conn, err = store.ConnectPostgres(); err != nil {
+		log.Println(err)
+
+		errors.WriteResponseError(w, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError))
+		return
+	}
+
+	defer conn.Close()
+
+	switch {
+	case templateId == "":
+		createTemplate(w, r, conn, clientId)
+	case action == "preview":
+		previewTemplate(w, r, conn, clientId, templateId)
+	case action == "versions":
+		getTemplateVersions(w, r, conn, clientId, templateId)
+	case r.Method == http.MethodDelete:
+		if err = models.DeleteTemplate(r.Context(), conn, clientId, templateId); err != nil {
+			errors.WriteResponseError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		getTemplate(w, r, conn, clientId, templateId)
+	}
+}
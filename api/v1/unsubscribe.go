@@ -1,17 +1,14 @@
 package v1
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 
 	api_utils "github.com/saschazar21/go-web-push-server/api/_utils"
 	"github.com/saschazar21/go-web-push-server/auth"
-	"github.com/saschazar21/go-web-push-server/db"
 	"github.com/saschazar21/go-web-push-server/errors"
-	"github.com/saschazar21/go-web-push-server/models"
-	"github.com/uptrace/bun"
+	"github.com/saschazar21/go-web-push-server/store"
 )
 
 func decodeUnsubscribeRecipient(r *http.Request) (recipientId string, err error) {
@@ -34,6 +31,11 @@ func decodeUnsubscribeRecipient(r *http.Request) (recipientId string, err error)
 
 func HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 	log.Println(r.URL.String())
+
+	api_utils.WithRateLimit("unsubscribe", handleUnsubscribe)(w, r)
+}
+
+func handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	var recipientId string
@@ -68,7 +70,7 @@ func HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := db.Connect()
+	s, err := store.Open()
 
 	if err != nil {
 		log.Println(err)
@@ -77,12 +79,12 @@ func HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	defer conn.Close()
+	defer s.Close()
 
 	ctx := r.Context()
 
 	var exists bool
-	if exists, err = models.HasExistingSubscriptionsByClientId(ctx, conn, clientId); err != nil || !exists {
+	if exists, err = s.HasExistingByClientId(ctx, clientId); err != nil || !exists {
 		if err != nil {
 			log.Println(err)
 
@@ -96,39 +98,18 @@ func HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var tx bun.Tx
-	tx, err = conn.BeginTx(ctx, &sql.TxOptions{})
-
-	if err != nil {
-		log.Println(err)
-
-		errors.WriteResponseError(w, err)
-		return
-	}
-
 	if recipientId == "" {
-		if err = models.DeleteSubscriptionsByClientId(ctx, tx, clientId); err != nil {
-			log.Println(err)
-
-			errors.WriteResponseError(w, err)
-			tx.Rollback()
-			return
-		}
-
-		tx.Commit()
-		w.WriteHeader(http.StatusNoContent)
-
-		return
+		err = s.DeleteByClientId(ctx, clientId)
+	} else {
+		err = s.DeleteByClientIdAndRecipientId(ctx, clientId, recipientId)
 	}
 
-	if err = models.DeleteSubscriptionsByClientIdAndRecipientId(ctx, tx, clientId, recipientId); err != nil {
+	if err != nil {
 		log.Println(err)
 
 		errors.WriteResponseError(w, err)
-		tx.Rollback()
 		return
 	}
 
-	tx.Commit()
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -13,10 +13,12 @@ import (
 	"github.com/saschazar21/go-web-push-server/auth"
 	"github.com/saschazar21/go-web-push-server/db"
 	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/store"
 	webpush_test "github.com/saschazar21/go-web-push-server/test"
 	"github.com/saschazar21/go-web-push-server/utils"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/uptrace/bun/extra/bundebug"
+	"gotest.tools/v3/assert"
 )
 
 func TestHandleUnsubscribe(t *testing.T) {
@@ -229,3 +231,63 @@ func TestHandleUnsubscribe(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleUnsubscribeMemoryStore(t *testing.T) {
+	basicAuthPassword := "123"
+	t.Setenv(auth.BASIC_AUTH_PASSWORD_ENV, basicAuthPassword)
+	t.Setenv("CWD", "../../")
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+	t.Setenv(utils.SUBSCRIPTION_STORE_ENV, store.STORE_MEMORY)
+
+	ctx := context.Background()
+	clientId := "memory unsubscribe client"
+
+	s, err := store.Open()
+	assert.NilError(t, err)
+
+	for _, recipientId := range []string{"first user", "second user"} {
+		endpoint := "https://example.com/" + url.PathEscape(recipientId)
+		decodedClientKey, _ := base64.RawURLEncoding.DecodeString("BPZ_GnkGFYfUcY0D0yMWcAQIuvQfV5tSw_dd7iIQktNR1dhdDflA1eQyJT-0ZSwpDO43mNbBwogEMTh7TCSkuP0")
+		decodedAuthSecret, _ := base64.RawURLEncoding.DecodeString("DGv6ra1nlYgDCS1FRnbzlw")
+
+		sub := &models.PushSubscription{
+			ClientId:    clientId,
+			RecipientId: recipientId,
+			Endpoint:    (*utils.EncryptedString)(&endpoint),
+			Keys: &models.SubscriptionKeys{
+				P256DH:     (*utils.EncryptedBytes)(&decodedClientKey),
+				AuthSecret: (*utils.EncryptedBytes)(&decodedAuthSecret),
+			},
+		}
+
+		assert.NilError(t, s.Save(ctx, sub))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(HandleUnsubscribe))
+	defer server.Close()
+
+	unsubscribe := func(recipientId string) int {
+		query := url.Values{}
+		query.Add("client", clientId)
+		query.Add("id", recipientId)
+
+		req, _ := http.NewRequest(http.MethodDelete, server.URL+"?"+query.Encode(), nil)
+		req.SetBasicAuth(clientId, basicAuthPassword)
+
+		res, err := server.Client().Do(req)
+		assert.NilError(t, err)
+
+		return res.StatusCode
+	}
+
+	assert.Equal(t, unsubscribe("first user"), http.StatusNoContent)
+
+	subscriptions, err := s.GetByClientId(ctx, clientId)
+	assert.NilError(t, err)
+	assert.Equal(t, len(subscriptions), 1)
+	assert.Equal(t, subscriptions[0].RecipientId, "second user")
+
+	assert.Equal(t, unsubscribe(""), http.StatusNoContent)
+	assert.Equal(t, unsubscribe(""), http.StatusNotFound)
+}
@@ -8,6 +8,7 @@ import (
 
 const (
 	BASIC_AUTH_PASSWORD_ENV = "BASIC_AUTH_PASSWORD"
+	CRON_SECRET_ENV         = "CRON_SECRET"
 )
 
 var (
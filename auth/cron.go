@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+)
+
+// HandleCronAuth verifies the bearer token sent by scheduled invocations, e.g. Vercel Cron Jobs, against the CRON_SECRET env.
+func HandleCronAuth(r *http.Request) (err error) {
+	secretEnv := os.Getenv(CRON_SECRET_ENV)
+
+	if secretEnv == "" {
+		log.Printf("missing environment variable %s\n", CRON_SECRET_ENV)
+
+		return errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError)
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if !ok || token == "" {
+		log.Println("missing bearer authentication")
+
+		return errors.NewResponseError(UNAUTHORIZED_ERROR, http.StatusUnauthorized, http.Header{
+			http.CanonicalHeaderKey("WWW-Authenticate"): []string{"Bearer realm=\"webpush\""},
+		})
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secretEnv)) != 1 {
+		log.Println("invalid bearer authentication")
+
+		return errors.NewResponseError(FORBIDDEN_ERROR, http.StatusForbidden)
+	}
+
+	return
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"gotest.tools/v3/assert"
+)
+
+func TestHandleCronAuth(t *testing.T) {
+	type test struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}
+
+	tests := []test{
+		{
+			"should return 401 Unauthorized on missing token",
+			"",
+			401,
+		},
+		{
+			"should return 401 Unauthorized on basic authentication",
+			"Basic YWRtaW46MTIz",
+			401,
+		},
+		{
+			"should return 403 Forbidden on invalid token",
+			"Bearer 456",
+			403,
+		},
+		{
+			"should return 500 Internal Server Error on unset environment variable",
+			"Bearer 123",
+			500,
+		},
+		{
+			"should accept valid token",
+			"Bearer 123",
+			200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantStatus != 500 {
+				t.Setenv(CRON_SECRET_ENV, "123")
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			err := HandleCronAuth(req)
+
+			if tt.wantStatus == 200 {
+				assert.NilError(t, err)
+			} else {
+				responseErr, _ := err.(errors.ResponseError)
+
+				assert.Equal(t, tt.wantStatus, responseErr.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/saschazar21/go-web-push-server/vapid"
+	"github.com/saschazar21/go-web-push-server/webpush"
+)
+
+type inspectHeader struct {
+	Salt        string `json:"salt"`
+	RecordSize  uint32 `json:"recordSize"`
+	KeyIdLength int    `json:"keyIdLength"`
+	KeyId       string `json:"keyId"`
+	PublicKey   string `json:"publicKey,omitempty"`
+}
+
+type inspectRecord struct {
+	Plaintext       string `json:"plaintext,omitempty"`
+	PlaintextBase64 string `json:"plaintextBase64,omitempty"`
+	PlaintextLength int    `json:"plaintextLength"`
+	Delimiter       string `json:"delimiter"`
+	PaddingLength   int    `json:"paddingLength"`
+}
+
+type inspectOutput struct {
+	Size           int            `json:"size"`
+	Header         *inspectHeader `json:"header,omitempty"`
+	CiphertextSize int            `json:"ciphertextSize"`
+	Record         *inspectRecord `json:"record,omitempty"`
+	Valid          bool           `json:"valid"`
+	Error          string         `json:"error,omitempty"`
+}
+
+func newInspectHeader(header *webpush.ContentCodingHeader) *inspectHeader {
+	h := &inspectHeader{
+		Salt:        base64.RawURLEncoding.EncodeToString(header.Salt),
+		RecordSize:  header.RecordSize,
+		KeyIdLength: len(header.KeyId),
+		KeyId:       base64.RawURLEncoding.EncodeToString(header.KeyId),
+	}
+
+	if header.PublicKey != nil {
+		h.PublicKey = base64.RawURLEncoding.EncodeToString(header.PublicKey.Bytes())
+	}
+
+	return h
+}
+
+func newInspectRecord(record *webpush.DecryptedRecord) *inspectRecord {
+	r := &inspectRecord{
+		PlaintextLength: len(record.Plaintext),
+		Delimiter:       fmt.Sprintf("0x%02x", record.Delimiter),
+		PaddingLength:   record.PaddingLength,
+	}
+
+	if utf8.Valid(record.Plaintext) {
+		r.Plaintext = string(record.Plaintext)
+	} else {
+		r.PlaintextBase64 = base64.RawURLEncoding.EncodeToString(record.Plaintext)
+	}
+
+	return r
+}
+
+// runInspect parses the content coding header of an encrypted push message body, and decrypts it, when given the receiver's keys.
+func runInspect(args []string) (err error) {
+	flags := newFlagSet("inspect")
+	in := flags.String("in", "", "path to the encrypted body, or - for stdin")
+	isBase64 := flags.Bool("base64", false, "the body is base64url-encoded instead of binary")
+	privateKey := flags.String("private-key", "", "optional base64url private key of the receiver, needed for decryption")
+	auth := flags.String("auth", "", "optional base64url auth secret of the receiver, needed for decryption")
+	flags.Parse(args)
+
+	if err = requireFlags(flags, "in"); err != nil {
+		return
+	}
+
+	body, err := readInput(*in)
+	if err != nil {
+		return
+	}
+
+	if *isBase64 {
+		if body, err = vapid.DecodeBase64(string(body)); err != nil {
+			return fmt.Errorf("failed to decode base64url body: %w", err)
+		}
+	}
+
+	output := &inspectOutput{Size: len(body)}
+
+	header, ciphertext, err := webpush.ParseContentCodingHeader(body)
+
+	if header != nil {
+		output.Header = newInspectHeader(header)
+		output.CiphertextSize = len(ciphertext)
+	}
+
+	if err == nil && (*privateKey != "" || *auth != "") {
+		err = decryptInspected(output, body, *privateKey, *auth)
+	}
+
+	output.Valid = err == nil
+
+	if err != nil {
+		output.Error = err.Error()
+	}
+
+	if writeErr := writeJSON(output); writeErr != nil {
+		return writeErr
+	}
+
+	return err
+}
+
+func decryptInspected(output *inspectOutput, body []byte, privateKey, auth string) (err error) {
+	if privateKey == "" || auth == "" {
+		return fmt.Errorf("both --private-key and --auth are required for decryption")
+	}
+
+	var buf, authSecret []byte
+
+	if buf, err = vapid.DecodeBase64(privateKey); err != nil {
+		return fmt.Errorf("failed to decode base64url private key: %w", err)
+	}
+
+	if authSecret, err = vapid.DecodeBase64(auth); err != nil {
+		return fmt.Errorf("failed to decode base64url auth secret: %w", err)
+	}
+
+	var key *ecdh.PrivateKey
+
+	if key, err = ecdh.P256().NewPrivateKey(buf); err != nil {
+		return fmt.Errorf("private key is no valid P-256 scalar: %w", err)
+	}
+
+	record, err := webpush.Decrypt(body, key, authSecret)
+
+	if record != nil {
+		output.Record = newInspectRecord(record)
+	}
+
+	return
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/saschazar21/go-web-push-server/vapid"
+)
+
+type vapidKeyPair struct {
+	Format     string `json:"format,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	PublicKey  string `json:"publicKey"`
+}
+
+var formatUsage = "the private key format: " + strings.Join(vapid.FORMATS, ", ")
+
+// readInput reads the file at path, or stdin for "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+func runKeygen(args []string) (err error) {
+	flags := newFlagSet("keygen")
+	pem := flags.Bool("pem", false, "print only the private key in PEM format, e.g. to redirect it into a file")
+	format := flags.String("format", vapid.FORMAT_SEC1, formatUsage)
+	flags.Parse(args)
+
+	key, err := vapid.GenerateVapidKey()
+	if err != nil {
+		return
+	}
+
+	if *pem {
+		var privateKey string
+
+		if privateKey, err = key.EncodeToPEM(true); err != nil {
+			return
+		}
+
+		_, err = fmt.Println(privateKey)
+		return
+	}
+
+	return writeKeyPair(key, *format)
+}
+
+type encoder interface {
+	Encode(format string) (string, error)
+	String() string
+}
+
+func writeKeyPair(key encoder, format string) (err error) {
+	var privateKey string
+
+	if privateKey, err = key.Encode(format); err != nil {
+		return
+	}
+
+	return writeJSON(&vapidKeyPair{
+		Format:     format,
+		PrivateKey: privateKey,
+		PublicKey:  key.String(),
+	})
+}
+
+// runConvert converts a private key between the supported formats, e.g. when migrating from the web-push npm package.
+func runConvert(args []string) (err error) {
+	flags := newFlagSet("convert")
+	in := flags.String("in", "", "path to the private key as PEM, JWK or raw base64url, or - for stdin")
+	publicKey := flags.String("public-key", "", "optional base64url public key, which must match the private key")
+	to := flags.String("to", vapid.FORMAT_SEC1, formatUsage)
+	flags.Parse(args)
+
+	if err = requireFlags(flags, "in"); err != nil {
+		return
+	}
+
+	buf, err := readInput(*in)
+	if err != nil {
+		return
+	}
+
+	key, err := vapid.Decode(string(buf))
+	if err != nil {
+		return
+	}
+
+	if *publicKey != "" {
+		if err = key.MatchesPublicKey(*publicKey); err != nil {
+			return
+		}
+	}
+
+	return writeKeyPair(key, *to)
+}
+
+func runPubkey(args []string) (err error) {
+	flags := newFlagSet("pubkey")
+	fromPEM := flags.String("from-pem", "", fmt.Sprintf("path to the private key as PEM, JWK or raw base64url, or - for stdin (defaults to the %s env)", utils.VAPID_PRIVATE_KEY_ENV))
+	flags.Parse(args)
+
+	raw := os.Getenv(utils.VAPID_PRIVATE_KEY_ENV)
+
+	if *fromPEM != "" {
+		var buf []byte
+
+		if buf, err = readInput(*fromPEM); err != nil {
+			return
+		}
+
+		raw = string(buf)
+	}
+
+	if raw == "" {
+		return fmt.Errorf("either --from-pem or the %s env is required", utils.VAPID_PRIVATE_KEY_ENV)
+	}
+
+	key, err := vapid.Decode(raw)
+	if err != nil {
+		return
+	}
+
+	return writeJSON(&vapidKeyPair{
+		PublicKey: key.String(),
+	})
+}
@@ -1,25 +1,103 @@
+// Command webpush manages VAPID keys and push subscriptions, and sends push messages directly against the configured database.
+// All commands write JSON to stdout, errors are written to stderr.
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sort"
 
-	"github.com/saschazar21/go-web-push-server/vapid"
+	"github.com/saschazar21/go-web-push-server/errors"
 )
 
-func main() {
-	key, err := vapid.GenerateVapidKey()
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"convert":   {"convert a VAPID private key between raw, PEM and JWK formats", runConvert},
+	"inspect":   {"inspect and decrypt an encrypted push message body", runInspect},
+	"keygen":    {"generate a new VAPID key pair", runKeygen},
+	"pubkey":    {"print the public key of a VAPID private key", runPubkey},
+	"subscribe": {"store a push subscription", runSubscribe},
+	"list":      {"list the push subscriptions of a client", runList},
+	"migrate":   {"apply, revert or list the database migrations: up, down or status", runMigrate},
+	"send":      {"send a push message to the subscriptions of a client", runSend},
+	"prune":     {"delete expired push subscriptions", runPrune},
+	"rehash":    {"rewrite the stored subscription hashes using the active HMAC_SECRET_KEY", runRehash},
+	"reencrypt": {"re-encrypt the stored subscription data with the active MASTER_KEY", runReencrypt},
+	"sweep":     {"delete expired push subscriptions past a grace period and orphaned keys", runSweep},
+}
 
-	if err != nil {
-		log.Fatalf("%v", err)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: webpush <command> [flags]\n\nCommands:\n")
+
+	names := make([]string, 0, len(commands))
+
+	for name := range commands {
+		names = append(names, name)
 	}
 
-	var s string
-	if s, err = key.EncodeToPEM(true); err != nil {
-		log.Fatalf("%v", err)
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
 	}
 
-	fmt.Printf("Private Key PEM:\n\n%s\n\n", s)
+	fmt.Fprintf(os.Stderr, "\nRun 'webpush <command> -h' for the flags of a command.\n")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("webpush "+name, flag.ExitOnError)
+}
+
+func writeJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
 
-	fmt.Printf("Public Key:\n\n%s\n", key.String())
+	return encoder.Encode(v)
+}
+
+// writeError writes JSON:API errors as is, and wraps any other error in a JSON object.
+func writeError(err error) {
+	if responseErr, ok := err.(errors.ResponseError); ok {
+		fmt.Fprintln(os.Stderr, responseErr.Body)
+		return
+	}
+
+	buf, _ := json.Marshal(map[string]string{"error": err.Error()})
+	fmt.Fprintln(os.Stderr, string(buf))
+}
+
+func main() {
+	// the packages log verbosely, which would clutter the output for scripts
+	if os.Getenv("DEBUG") == "" {
+		log.SetOutput(io.Discard)
+	}
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+
+	if !ok {
+		if os.Args[1] != "-h" && os.Args[1] != "--help" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		}
+
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		writeError(err)
+		os.Exit(1)
+	}
 }
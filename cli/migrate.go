@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/saschazar21/go-web-push-server/db"
+)
+
+func runMigrate(args []string) (err error) {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand, expected one of up, down or status")
+	}
+
+	flags := newFlagSet("migrate " + args[0])
+	steps := flags.Int("steps", 1, "the amount of migrations reverted by down")
+	flags.Parse(args[1:])
+
+	// the schema check of db.Connect would refuse an outdated database, which is what needs migrating
+	conn, err := db.Open()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	var statuses []db.MigrationStatus
+
+	switch args[0] {
+	case "up":
+		statuses, err = db.Migrate(ctx, conn)
+	case "down":
+		if *steps < 1 {
+			return fmt.Errorf("--steps must be at least 1")
+		}
+
+		statuses, err = db.Rollback(ctx, conn, *steps)
+	case "status":
+		statuses, err = db.GetMigrationStatus(ctx, conn)
+	default:
+		return fmt.Errorf("unknown subcommand: %s, expected one of up, down or status", args[0])
+	}
+
+	if err != nil {
+		return
+	}
+
+	return writeJSON(statuses)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/uptrace/bun"
+)
+
+func runRehash(args []string) (err error) {
+	flags := newFlagSet("rehash")
+	batchSize := flags.Int("batch-size", models.DEFAULT_REHASH_BATCH_SIZE, "the amount of rows rehashed per transaction")
+	flags.Parse(args)
+
+	return withDB(func(ctx context.Context, conn *bun.DB) error {
+		result, err := models.Rehash(ctx, conn, *batchSize)
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(result)
+	})
+}
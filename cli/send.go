@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/saschazar21/go-web-push-server/webpush"
+	"github.com/uptrace/bun"
+)
+
+type sendResult struct {
+	*subscriptionOutput
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Pruned bool   `json:"pruned,omitempty"`
+}
+
+type sendOutput struct {
+	Sent           int           `json:"sent"`
+	Failed         int           `json:"failed"`
+	PrunedByPolicy int64         `json:"prunedByPolicy"`
+	Results        []*sendResult `json:"results"`
+}
+
+// readPayload reads the payload from a file for @path, from stdin for @-, or takes the value literally.
+func readPayload(value string) ([]byte, error) {
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		return readInput(path)
+	}
+
+	return []byte(value), nil
+}
+
+// sendToSubscription reports the result of sending the payload, and the delivery, unless sending was not attempted at all.
+func sendToSubscription(sub *models.PushSubscription, payload []byte, params *request.WithWebPushParams) (result *sendResult, delivery *models.Delivery) {
+	result = &sendResult{subscriptionOutput: newSubscriptionOutput(sub)}
+
+	push, err := webpush.NewWebPush(sub)
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Error = err.Error()
+		return
+	}
+
+	res, err := push.Send(payload, params)
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Error = err.Error()
+		delivery = models.NewDelivery(push.Endpoint, 0)
+		return
+	}
+
+	defer res.Body.Close()
+
+	result.Status = res.StatusCode
+	delivery = models.NewDelivery(push.Endpoint, res.StatusCode)
+
+	if res.StatusCode >= http.StatusBadRequest {
+		result.Error = http.StatusText(res.StatusCode)
+	}
+
+	return
+}
+
+func runSend(args []string) (err error) {
+	flags := newFlagSet("send")
+	clientId := flags.String("client", "", "the client ID")
+	recipientId := flags.String("recipient", "", "optional recipient ID, otherwise all recipients of the client are targeted")
+	payloadFlag := flags.String("payload", "", "the push message payload, @path to read it from a file, or @- for stdin")
+	ttl := flags.Int64("ttl", 0, "the TTL of the push message in seconds")
+	topic := flags.String("topic", "", "optional topic, replacing pending push messages with the same topic")
+	urgency := flags.String("urgency", "", "optional urgency: very-low, low, normal or high")
+	flags.Parse(args)
+
+	if err = requireFlags(flags, "client", "payload"); err != nil {
+		return
+	}
+
+	payload, err := readPayload(*payloadFlag)
+	if err != nil {
+		return
+	}
+
+	if err = webpush.ValidatePayloadSize(payload); err != nil {
+		return
+	}
+
+	params := &request.WithWebPushParams{
+		TTL:     *ttl,
+		Topic:   *topic,
+		Urgency: *urgency,
+	}
+
+	if err = utils.CustomValidateStruct(params); err != nil {
+		return
+	}
+
+	return withDB(func(ctx context.Context, conn *bun.DB) error {
+		subs, err := getSubscriptions(ctx, conn, *clientId, *recipientId)
+		if err != nil {
+			return err
+		}
+
+		output := &sendOutput{Results: make([]*sendResult, 0, len(subs))}
+		deliveries := make([]*models.Delivery, 0, len(subs))
+
+		for _, sub := range subs {
+			result, delivery := sendToSubscription(sub, payload, params)
+
+			if delivery != nil {
+				deliveries = append(deliveries, delivery)
+			}
+
+			switch {
+			case delivery != nil && delivery.Succeeded():
+				output.Sent++
+			case result.Status == http.StatusNotFound, result.Status == http.StatusGone:
+				// same as the API, subscriptions unknown to the push service are deleted
+				result.Pruned = models.DeleteSubscriptionByEndpoint(ctx, conn, string(*sub.Endpoint)) == nil
+				output.Failed++
+			default:
+				output.Failed++
+			}
+
+			output.Results = append(output.Results, result)
+		}
+
+		// the push messages are sent already, so the output is written regardless
+		saveErr := models.SaveDeliveries(ctx, conn, deliveries)
+
+		if saveErr == nil {
+			output.PrunedByPolicy, saveErr = models.ApplyPruningPolicy(ctx, conn, *clientId, deliveries)
+		}
+
+		if err := writeJSON(output); err != nil {
+			return err
+		}
+
+		if saveErr != nil {
+			return saveErr
+		}
+
+		if output.Failed > 0 {
+			return fmt.Errorf("%d of %d push messages failed", output.Failed, len(subs))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/request"
+	"github.com/saschazar21/go-web-push-server/store"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+type subscriptionOutput struct {
+	Hash           string             `json:"hash"`
+	ClientId       string             `json:"clientId"`
+	RecipientId    string             `json:"recipientId"`
+	Locale         string             `json:"locale"`
+	PushService    string             `json:"pushService"`
+	ExpirationTime *utils.EpochMillis `json:"expirationTime,omitempty"`
+	Tags           []string           `json:"tags,omitempty"`
+
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty"`
+	LastStatusCode      int        `json:"lastStatusCode,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+}
+
+func newSubscriptionOutput(sub *models.PushSubscription) *subscriptionOutput {
+	hash := utils.Hash([]byte(*sub.Endpoint))
+
+	return &subscriptionOutput{
+		Hash:           base64.RawURLEncoding.EncodeToString(hash[:]),
+		ClientId:       sub.ClientId,
+		RecipientId:    sub.RecipientId,
+		Locale:         sub.Locale,
+		PushService:    sub.PushService,
+		ExpirationTime: sub.ExpirationTime,
+		Tags:           sub.Tags,
+
+		LastSuccessAt:       sub.LastSuccessAt,
+		LastFailureAt:       sub.LastFailureAt,
+		LastStatusCode:      sub.LastStatusCode,
+		ConsecutiveFailures: sub.ConsecutiveFailures,
+	}
+}
+
+// withDB connects to the database configured by the POSTGRES_CONNECTION_STRING env.
+func withDB(fn func(ctx context.Context, conn *bun.DB) error) (err error) {
+	conn, err := store.ConnectPostgres()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	return fn(context.Background(), conn)
+}
+
+// requireFlags fails, when any of the named flags is empty.
+func requireFlags(flags *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if flags.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("--%s is required", name)
+		}
+	}
+
+	return nil
+}
+
+// getSubscriptions returns all subscriptions of a client, or of a single recipient, if given.
+func getSubscriptions(ctx context.Context, conn bun.IDB, clientId, recipientId string) ([]*models.PushSubscription, error) {
+	if recipientId != "" {
+		return models.GetSubscriptionsByClientIdAndRecipientId(ctx, conn, clientId, recipientId)
+	}
+
+	return models.GetSubscriptionsByClientId(ctx, conn, clientId)
+}
+
+func runSubscribe(args []string) (err error) {
+	flags := newFlagSet("subscribe")
+	file := flags.String("file", "", "path to the subscription JSON, as sent to POST /api/v1/subscribe, or - for stdin")
+	flags.Parse(args)
+
+	if err = requireFlags(flags, "file"); err != nil {
+		return
+	}
+
+	buf, err := readInput(*file)
+	if err != nil {
+		return
+	}
+
+	// parsing an in-memory request applies the same validation and endpoint policy as the API
+	req, err := http.NewRequest(http.MethodPost, "/api/v1/subscribe", bytes.NewReader(buf))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", utils.APPLICATION_JSON)
+
+	sub, err := request.ParseSubscriptionRequest(req)
+	if err != nil {
+		return
+	}
+
+	return withDB(func(ctx context.Context, conn *bun.DB) error {
+		if err := conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			return sub.Save(ctx, tx)
+		}); err != nil {
+			return err
+		}
+
+		return writeJSON(newSubscriptionOutput(sub))
+	})
+}
+
+func runList(args []string) (err error) {
+	flags := newFlagSet("list")
+	clientId := flags.String("client", "", "the client ID")
+	recipientId := flags.String("recipient", "", "optional recipient ID")
+	flags.Parse(args)
+
+	if err = requireFlags(flags, "client"); err != nil {
+		return
+	}
+
+	return withDB(func(ctx context.Context, conn *bun.DB) error {
+		subs, err := getSubscriptions(ctx, conn, *clientId, *recipientId)
+		if err != nil {
+			return err
+		}
+
+		output := make([]*subscriptionOutput, 0, len(subs))
+
+		for _, sub := range subs {
+			output = append(output, newSubscriptionOutput(sub))
+		}
+
+		return writeJSON(output)
+	})
+}
+
+func runPrune(args []string) (err error) {
+	flags := newFlagSet("prune")
+	clientId := flags.String("client", "", "optional client ID, otherwise expired subscriptions of all clients are deleted")
+	flags.Parse(args)
+
+	return withDB(func(ctx context.Context, conn *bun.DB) error {
+		deleted, err := models.DeleteExpiredSubscriptions(ctx, conn, *clientId)
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(map[string]int64{"deleted": deleted})
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/uptrace/bun"
+)
+
+func runSweep(args []string) (err error) {
+	flags := newFlagSet("sweep")
+	grace := flags.Duration("grace", models.GetSweepGracePeriod(), "how long expired subscriptions are retained, defaults to the SWEEP_GRACE_PERIOD env")
+	interval := flags.Duration("interval", 0, "keep running and sweep in the given interval, e.g. 1h, instead of sweeping once")
+	flags.Parse(args)
+
+	return withDB(func(ctx context.Context, conn *bun.DB) error {
+		if *interval <= 0 {
+			result, err := models.Sweep(ctx, conn, *grace)
+			if err != nil {
+				return err
+			}
+
+			return writeJSON(result)
+		}
+
+		// the sweeper reports its counts through the log, until interrupted
+		log.SetOutput(os.Stderr)
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		models.RunSweeper(ctx, conn, *interval, *grace)
+
+		return nil
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/store"
+)
+
+// handleSweep runs as a scheduled function, the invocation event carries no relevant information.
+func handleSweep(ctx context.Context) (result *models.SweepResult, err error) {
+	conn, err := store.ConnectPostgres()
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	return models.Sweep(ctx, conn, models.GetSweepGracePeriod())
+}
+
+func main() {
+	lambda.Start(handleSweep)
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+	v1 "github.com/saschazar21/go-web-push-server/api/v1"
+)
+
+func main() {
+	lambda.Start(httpadapter.New(http.HandlerFunc(v1.HandleTags)).ProxyWithContext)
+}
@@ -1,9 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"os"
+	"sync"
 
 	"github.com/saschazar21/go-web-push-server/utils"
 
@@ -13,13 +16,25 @@ import (
 	"github.com/uptrace/bun/extra/bundebug"
 )
 
-func Connect() (db *bun.DB, err error) {
+var (
+	preparedMu sync.Mutex
+	// prepared holds the connection strings, whose databases were migrated and checked by the current process
+	prepared = map[string]bool{}
+)
+
+// Open connects to the database configured by the POSTGRES_CONNECTION_STRING env, without migrating or checking its schema.
+func Open() (db *bun.DB, err error) {
 	dsn := os.Getenv(utils.POSTGRES_CONNECTION_STRING_ENV)
 
 	if dsn == "" {
 		return nil, fmt.Errorf("%v env not set", utils.POSTGRES_CONNECTION_STRING_ENV)
 	}
 
+	// the hashed lookup columns can't be migrated silently, so refuse to operate on them without a secret
+	if err = utils.ValidateHashSecret(); err != nil {
+		return nil, err
+	}
+
 	sqlDb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn)))
 	db = bun.NewDB(sqlDb, pgdialect.New())
 
@@ -30,3 +45,50 @@ func Connect() (db *bun.DB, err error) {
 
 	return
 }
+
+// Connect opens the database like Open. On the first connection of the process, it applies pending migrations,
+// when the MIGRATE_ON_START env is true, and checks that the schema matches the models, unless SKIP_SCHEMA_CHECK is set.
+func Connect() (db *bun.DB, err error) {
+	if db, err = Open(); err != nil {
+		return
+	}
+
+	if err = prepare(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return
+}
+
+func prepare(ctx context.Context, db *bun.DB) (err error) {
+	dsn := os.Getenv(utils.POSTGRES_CONNECTION_STRING_ENV)
+
+	preparedMu.Lock()
+	defer preparedMu.Unlock()
+
+	if prepared[dsn] {
+		return
+	}
+
+	if os.Getenv(utils.MIGRATE_ON_START_ENV) == "true" {
+		var applied []MigrationStatus
+		if applied, err = Migrate(ctx, db); err != nil {
+			return
+		}
+
+		for _, m := range applied {
+			log.Printf("applied migration %s_%s", m.Version, m.Name)
+		}
+	}
+
+	if os.Getenv(utils.SKIP_SCHEMA_CHECK_ENV) == "" {
+		if err = CheckSchema(ctx, db); err != nil {
+			return
+		}
+	}
+
+	prepared[dsn] = true
+
+	return
+}
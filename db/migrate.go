@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+// The migrations are named <version>_<comment>.tx.up.sql and .tx.down.sql, and run within a transaction each.
+// They only create what is missing, so databases set up by hand may adopt them.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	MIGRATIONS_TABLE       = "webpush_schema_migrations"
+	MIGRATIONS_LOCKS_TABLE = "webpush_schema_migration_locks"
+
+	// MIGRATIONS_ADVISORY_LOCK serializes concurrent migrations, e.g. by several serverless functions migrating on start.
+	MIGRATIONS_ADVISORY_LOCK int64 = 0x77656270757368
+)
+
+type MigrationStatus struct {
+	Version    string     `json:"version"`
+	Name       string     `json:"name"`
+	Applied    bool       `json:"applied"`
+	MigratedAt *time.Time `json:"migratedAt,omitempty"`
+}
+
+func newMigrationStatus(m migrate.Migration) MigrationStatus {
+	status := MigrationStatus{
+		Version: m.Name,
+		Name:    m.Comment,
+		Applied: m.IsApplied(),
+	}
+
+	if status.Applied {
+		migratedAt := m.MigratedAt
+		status.MigratedAt = &migratedAt
+	}
+
+	return status
+}
+
+func newMigrator(db *bun.DB) (*migrate.Migrator, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := migrate.NewMigrations()
+
+	if err = migrations.Discover(files); err != nil {
+		return nil, fmt.Errorf("failed to discover migrations: %w", err)
+	}
+
+	return migrate.NewMigrator(
+		db,
+		migrations,
+		migrate.WithTableName(MIGRATIONS_TABLE),
+		migrate.WithLocksTableName(MIGRATIONS_LOCKS_TABLE),
+		// a failed migration must be retried on the next run
+		migrate.WithMarkAppliedOnSuccess(true),
+	), nil
+}
+
+// withMigrationLock holds a session-level advisory lock while running fn, which Postgres releases, should the process die.
+func withMigrationLock(ctx context.Context, db *bun.DB, fn func(migrator *migrate.Migrator) error) (err error) {
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", MIGRATIONS_ADVISORY_LOCK); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", MIGRATIONS_ADVISORY_LOCK)
+
+	if err = migrator.Init(ctx); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", MIGRATIONS_TABLE, err)
+	}
+
+	return fn(migrator)
+}
+
+// Migrate applies all pending migrations in order and returns them, stopping at the first failing migration.
+func Migrate(ctx context.Context, db *bun.DB) (applied []MigrationStatus, err error) {
+	applied = make([]MigrationStatus, 0)
+
+	err = withMigrationLock(ctx, db, func(migrator *migrate.Migrator) error {
+		group, err := migrator.Migrate(ctx)
+
+		if group != nil {
+			for _, m := range group.Migrations {
+				if m.IsApplied() {
+					applied = append(applied, newMigrationStatus(m))
+				}
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to apply migration: %w", err)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// Rollback reverts the given number of most recently applied migrations and returns them, latest first.
+func Rollback(ctx context.Context, db *bun.DB, steps int) (reverted []MigrationStatus, err error) {
+	reverted = make([]MigrationStatus, 0)
+
+	err = withMigrationLock(ctx, db, func(migrator *migrate.Migrator) error {
+		migrations, err := migrator.MigrationsWithStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i, m := range migrations.Applied() {
+			if i >= steps {
+				break
+			}
+
+			if m.Down == nil {
+				return fmt.Errorf("migration %s has no down migration", m)
+			}
+
+			if err = m.Down(ctx, db); err != nil {
+				return fmt.Errorf("failed to revert migration %s: %w", m, err)
+			}
+
+			if err = migrator.MarkUnapplied(ctx, &m); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, MigrationStatus{Version: m.Name, Name: m.Comment})
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// GetMigrationStatus returns all embedded migrations in order, along with whether they were applied to the database.
+func GetMigrationStatus(ctx context.Context, db *bun.DB) (statuses []MigrationStatus, err error) {
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return
+	}
+
+	if err = migrator.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create %s table: %w", MIGRATIONS_TABLE, err)
+	}
+
+	migrations, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return
+	}
+
+	statuses = make([]MigrationStatus, 0, len(migrations))
+
+	for _, m := range migrations {
+		statuses = append(statuses, newMigrationStatus(m))
+	}
+
+	return
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/uptrace/bun/migrate"
+	"gotest.tools/v3/assert"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	assert.NilError(t, err)
+
+	migrations := migrate.NewMigrations()
+	assert.NilError(t, migrations.Discover(files))
+
+	sorted := migrations.Sorted()
+	assert.Assert(t, len(sorted) > 0)
+
+	for i, m := range sorted {
+		assert.Assert(t, m.Up != nil, "migration %s has no up migration", m)
+		assert.Assert(t, m.Down != nil, "migration %s has no down migration", m)
+		assert.Assert(t, m.Comment != "", "migration %s has no name", m)
+
+		if i > 0 {
+			assert.Assert(t, sorted[i-1].Name < m.Name, "migration %s shares its version with %s", m, sorted[i-1])
+		}
+	}
+}
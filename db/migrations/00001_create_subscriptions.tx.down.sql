@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS webpush_keys;
+DROP TABLE IF EXISTS webpush_subscriptions;
@@ -1,5 +1,5 @@
 -- Create the subscriptions table with a many-to-one relation to recipients
-CREATE TABLE webpush_subscriptions (
+CREATE TABLE IF NOT EXISTS webpush_subscriptions (
   endpoint_hash BYTEA PRIMARY KEY,
   endpoint BYTEA NOT NULL,
   expiration_time TIMESTAMPTZ,
@@ -8,13 +8,13 @@ CREATE TABLE webpush_subscriptions (
 );
 
 -- Create indexes for efficient querying
-CREATE INDEX idx_subscription_recipient_id ON webpush_subscriptions(recipient_id);
-CREATE INDEX idx_subscription_client_id ON webpush_subscriptions(client_id);
-CREATE INDEX idx_subscription_expiration_time ON webpush_subscriptions(expiration_time)
+CREATE INDEX IF NOT EXISTS idx_subscription_recipient_id ON webpush_subscriptions(recipient_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_client_id ON webpush_subscriptions(client_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_expiration_time ON webpush_subscriptions(expiration_time)
   WHERE expiration_time IS NOT NULL;
 
 -- Create the keys table with a one-to-one relation to subscriptions
-CREATE TABLE webpush_keys (
+CREATE TABLE IF NOT EXISTS webpush_keys (
   p256dh_hash BYTEA PRIMARY KEY,
   p256dh BYTEA NOT NULL,
   auth_secret_hash BYTEA NOT NULL UNIQUE,
@@ -26,4 +26,4 @@ CREATE TABLE webpush_keys (
 );
 
 -- Create indexes for efficient querying
-CREATE UNIQUE INDEX idx_keys_subscription_hash ON webpush_keys(subscription_hash);
\ No newline at end of file
+CREATE UNIQUE INDEX IF NOT EXISTS idx_keys_subscription_hash ON webpush_keys(subscription_hash);
@@ -0,0 +1,14 @@
+-- Create the templates table, every change to a template is stored as a new version
+CREATE TABLE IF NOT EXISTS webpush_templates (
+  client_id VARCHAR(255) NOT NULL,
+  template_id VARCHAR(255) NOT NULL,
+  version INTEGER NOT NULL,
+  title TEXT NOT NULL,
+  body TEXT,
+  icon TEXT,
+  tag TEXT,
+  url TEXT,
+  data JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (client_id, template_id, version)
+);
@@ -0,0 +1 @@
+ALTER TABLE webpush_subscriptions DROP COLUMN IF EXISTS locale;
@@ -0,0 +1 @@
+ALTER TABLE webpush_subscriptions ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT 'en';
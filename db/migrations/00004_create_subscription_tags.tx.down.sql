@@ -0,0 +1 @@
+DROP TABLE IF EXISTS webpush_subscription_tags;
@@ -0,0 +1,12 @@
+-- Create the tags table with a many-to-one relation to subscriptions
+CREATE TABLE IF NOT EXISTS webpush_subscription_tags (
+  subscription_hash BYTEA NOT NULL,
+  tag VARCHAR(255) NOT NULL,
+  PRIMARY KEY (subscription_hash, tag),
+  FOREIGN KEY (subscription_hash) REFERENCES webpush_subscriptions(endpoint_hash) ON
+  DELETE
+    CASCADE
+);
+
+-- Create indexes for efficient querying
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag ON webpush_subscription_tags(tag);
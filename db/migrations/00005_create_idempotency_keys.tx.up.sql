@@ -0,0 +1,15 @@
+-- Create the idempotency keys table, storing the response of a push request per client and key
+CREATE TABLE IF NOT EXISTS webpush_idempotency_keys (
+  client_id VARCHAR(255) NOT NULL,
+  idempotency_key VARCHAR(255) NOT NULL,
+  fingerprint BYTEA NOT NULL,
+  status_code INTEGER NOT NULL DEFAULT 0,
+  content_type VARCHAR(255),
+  body BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (client_id, idempotency_key)
+);
+
+-- Create indexes for efficient querying
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON webpush_idempotency_keys(expires_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS webpush_quota_usage;
+DROP TABLE IF EXISTS webpush_rate_limits;
@@ -0,0 +1,14 @@
+-- Create the rate limits table, storing a token bucket per client and endpoint
+CREATE TABLE IF NOT EXISTS webpush_rate_limits (
+  bucket_key VARCHAR(512) PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ
+);
+
+-- Create the quota usage table, counting delivered push notifications per client and day or month
+CREATE TABLE IF NOT EXISTS webpush_quota_usage (
+  client_id VARCHAR(255) NOT NULL,
+  period VARCHAR(10) NOT NULL,
+  delivered BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (client_id, period)
+);
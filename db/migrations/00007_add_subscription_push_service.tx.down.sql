@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscription_push_service;
+
+ALTER TABLE webpush_subscriptions DROP COLUMN IF EXISTS push_service;
@@ -0,0 +1,3 @@
+ALTER TABLE webpush_subscriptions ADD COLUMN IF NOT EXISTS push_service VARCHAR(32) NOT NULL DEFAULT 'unknown';
+
+CREATE INDEX IF NOT EXISTS idx_subscription_push_service ON webpush_subscriptions(push_service);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscription_client_id_created_at;
+
+ALTER TABLE webpush_subscriptions DROP COLUMN IF EXISTS created_at;
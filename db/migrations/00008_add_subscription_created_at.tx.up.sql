@@ -0,0 +1,3 @@
+ALTER TABLE webpush_subscriptions ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_subscription_client_id_created_at ON webpush_subscriptions(client_id, created_at, endpoint_hash);
@@ -0,0 +1,6 @@
+ALTER TABLE webpush_subscriptions
+  DROP COLUMN IF EXISTS updated_at,
+  DROP COLUMN IF EXISTS last_success_at,
+  DROP COLUMN IF EXISTS last_failure_at,
+  DROP COLUMN IF EXISTS last_status_code,
+  DROP COLUMN IF EXISTS consecutive_failures;
@@ -0,0 +1,6 @@
+ALTER TABLE webpush_subscriptions
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS last_failure_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS last_status_code INTEGER,
+  ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
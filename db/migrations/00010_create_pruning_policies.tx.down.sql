@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webpush_pruning_policies;
+DROP TABLE IF EXISTS webpush_delivery_failures;
+
+ALTER TABLE webpush_subscriptions DROP COLUMN IF EXISTS quarantined_at;
@@ -0,0 +1,24 @@
+ALTER TABLE webpush_subscriptions ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMPTZ;
+
+-- Create the delivery failures table, recording the failed deliveries of a subscription since its last successful delivery
+CREATE TABLE IF NOT EXISTS webpush_delivery_failures (
+  subscription_hash BYTEA NOT NULL,
+  status_code INTEGER NOT NULL,
+  failed_at TIMESTAMPTZ NOT NULL,
+  FOREIGN KEY (subscription_hash) REFERENCES webpush_subscriptions(endpoint_hash) ON
+  DELETE
+    CASCADE
+);
+
+-- Create indexes for efficient querying
+CREATE INDEX IF NOT EXISTS idx_delivery_failures_subscription_hash ON webpush_delivery_failures(subscription_hash, failed_at);
+
+-- Create the pruning policies table, storing the policy for repeatedly failing subscriptions per client
+CREATE TABLE IF NOT EXISTS webpush_pruning_policies (
+  client_id VARCHAR(255) PRIMARY KEY,
+  action VARCHAR(16) NOT NULL,
+  max_failures INTEGER NOT NULL,
+  window_days INTEGER NOT NULL,
+  status_codes INTEGER [] NOT NULL DEFAULT '{}',
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
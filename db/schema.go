@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+var (
+	modelsMu sync.RWMutex
+	models   []any
+)
+
+// RegisterModels adds bun models, e.g. (*Model)(nil), whose tables and columns CheckSchema expects in the database.
+func RegisterModels(m ...any) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+
+	models = append(models, m...)
+}
+
+type schemaColumn struct {
+	TableName  string `bun:"table_name"`
+	ColumnName string `bun:"column_name"`
+}
+
+// CheckSchema fails, when any table or column of the registered models is missing in the current schema of the database.
+func CheckSchema(ctx context.Context, db bun.IDB) (err error) {
+	modelsMu.RLock()
+	registered := append([]any{}, models...)
+	modelsMu.RUnlock()
+
+	if len(registered) == 0 {
+		return
+	}
+
+	// several models may map to the same table, e.g. subsets of the columns
+	expected := map[string][]string{}
+	tableNames := make([]string, 0)
+
+	for _, model := range registered {
+		table := db.Dialect().Tables().Get(reflect.TypeOf(model))
+
+		if _, ok := expected[table.Name]; !ok {
+			tableNames = append(tableNames, table.Name)
+		}
+
+		for _, field := range table.Fields {
+			expected[table.Name] = append(expected[table.Name], field.Name)
+		}
+	}
+
+	var columns []schemaColumn
+
+	if err = db.NewSelect().
+		TableExpr("information_schema.columns").
+		Column("table_name", "column_name").
+		Where("table_schema = current_schema()").
+		Where("table_name IN (?)", bun.In(tableNames)).
+		Scan(ctx, &columns); err != nil {
+		return fmt.Errorf("failed to read database schema: %w", err)
+	}
+
+	existing := map[string]map[string]bool{}
+
+	for _, column := range columns {
+		if existing[column.TableName] == nil {
+			existing[column.TableName] = map[string]bool{}
+		}
+
+		existing[column.TableName][column.ColumnName] = true
+	}
+
+	missing := make([]string, 0)
+
+	for _, tableName := range tableNames {
+		if existing[tableName] == nil {
+			missing = append(missing, fmt.Sprintf("table %s", tableName))
+			continue
+		}
+
+		for _, columnName := range expected[tableName] {
+			if !existing[tableName][columnName] {
+				missing = append(missing, fmt.Sprintf("column %s.%s", tableName, columnName))
+				existing[tableName][columnName] = true
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("database schema does not match the models, missing %s; run `webpush migrate up` or set %s=true", strings.Join(missing, ", "), utils.MIGRATE_ON_START_ENV)
+	}
+
+	return
+}
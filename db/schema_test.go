@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	_ "github.com/saschazar21/go-web-push-server/models"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"gotest.tools/v3/assert"
+)
+
+func TestMigrationsMatchModels(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	t.Cleanup(func() {
+		c.Terminate(ctx)
+	})
+
+	conn, err := db.Open()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	t.Run("applies every migration", func(t *testing.T) {
+		statuses, err := db.GetMigrationStatus(ctx, conn)
+		assert.NilError(t, err)
+
+		for _, status := range statuses {
+			assert.Assert(t, status.Applied, "migration %s_%s is pending", status.Version, status.Name)
+		}
+
+		assert.NilError(t, db.CheckSchema(ctx, conn))
+	})
+
+	t.Run("reverts and reapplies the latest migration", func(t *testing.T) {
+		reverted, err := db.Rollback(ctx, conn, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(reverted), 1)
+
+		err = db.CheckSchema(ctx, conn)
+		assert.ErrorContains(t, err, "missing")
+
+		applied, err := db.Migrate(ctx, conn)
+		assert.NilError(t, err)
+		assert.Equal(t, len(applied), 1)
+		assert.Equal(t, applied[0].Version, reverted[0].Version)
+
+		assert.NilError(t, db.CheckSchema(ctx, conn))
+	})
+
+	t.Run("reports missing columns", func(t *testing.T) {
+		_, err := conn.ExecContext(ctx, "ALTER TABLE webpush_subscriptions DROP COLUMN locale")
+		assert.NilError(t, err)
+
+		err = db.CheckSchema(ctx, conn)
+		assert.Assert(t, err != nil && strings.Contains(err.Error(), "column webpush_subscriptions.locale"))
+	})
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+// Delivery is the outcome of sending a push message to a subscription endpoint.
+type Delivery struct {
+	Endpoint string
+	// StatusCode is the response status of the push service, or 0 if the push service was unreachable.
+	StatusCode int
+	At         time.Time
+}
+
+// DeliveryFailure records a failed delivery to a subscription since its last successful delivery.
+type DeliveryFailure struct {
+	bun.BaseModel `bun:"table:webpush_delivery_failures,alias:pdf"`
+
+	SubscriptionHash []byte    `bun:"subscription_hash,type:bytea,notnull"`
+	StatusCode       int       `bun:"status_code,notnull"`
+	FailedAt         time.Time `bun:"failed_at,notnull"`
+}
+
+func NewDelivery(endpoint string, statusCode int) *Delivery {
+	return &Delivery{
+		Endpoint:   endpoint,
+		StatusCode: statusCode,
+		At:         time.Now().UTC(),
+	}
+}
+
+func (d *Delivery) Succeeded() bool {
+	return d.StatusCode >= http.StatusOK && d.StatusCode < http.StatusMultipleChoices
+}
+
+// deliveryOutcome aggregates the deliveries to a subscription hash within a single call of SaveDeliveries,
+// i.e. either its last successful delivery, or the failed deliveries following it.
+type deliveryOutcome struct {
+	EndpointHash []byte    `bun:"endpoint_hash,type:bytea"`
+	StatusCode   int       `bun:"status_code,type:integer"`
+	At           time.Time `bun:"at,type:timestamptz"`
+	Failures     int       `bun:"failures,type:integer"`
+
+	failed []*Delivery
+}
+
+// groupDeliveries returns the outcomes of the last successful delivery per endpoint, and of the failed deliveries following it,
+// for every hash the endpoint may be stored by.
+func groupDeliveries(deliveries []*Delivery) (successes, failures []*deliveryOutcome) {
+	endpoints := make([]string, 0, len(deliveries))
+	byEndpoint := map[string][]*Delivery{}
+
+	for _, delivery := range deliveries {
+		if _, ok := byEndpoint[delivery.Endpoint]; !ok {
+			endpoints = append(endpoints, delivery.Endpoint)
+		}
+
+		byEndpoint[delivery.Endpoint] = append(byEndpoint[delivery.Endpoint], delivery)
+	}
+
+	for _, endpoint := range endpoints {
+		delivered := byEndpoint[endpoint]
+
+		slices.SortStableFunc(delivered, func(a, b *Delivery) int {
+			return a.At.Compare(b.At)
+		})
+
+		failed := delivered
+		var succeeded *Delivery
+
+		for i := len(delivered) - 1; i >= 0; i-- {
+			if delivered[i].Succeeded() {
+				succeeded, failed = delivered[i], delivered[i+1:]
+				break
+			}
+		}
+
+		// subscriptions not yet rehashed after rotating the HMAC secret are matched by their previous hash
+		for _, hash := range utils.Hashes([]byte(endpoint)) {
+			if succeeded != nil {
+				successes = append(successes, &deliveryOutcome{EndpointHash: hash, StatusCode: succeeded.StatusCode, At: succeeded.At})
+			}
+
+			if len(failed) > 0 {
+				last := failed[len(failed)-1]
+				failures = append(failures, &deliveryOutcome{EndpointHash: hash, StatusCode: last.StatusCode, At: last.At, Failures: len(failed), failed: failed})
+			}
+		}
+	}
+
+	return
+}
+
+// SaveDeliveries updates the delivery metadata of the delivered subscriptions, i.e. the time of the last success or failure,
+// the last status code, and the amount of consecutive failures, which is reset by every successful delivery.
+// Failures are recorded individually until the next successful delivery, to be evaluated by the pruning policy.
+// All deliveries are saved in a fixed amount of statements, regardless of their amount.
+func SaveDeliveries(ctx context.Context, db bun.IDB, deliveries []*Delivery) (err error) {
+	if len(deliveries) == 0 {
+		return
+	}
+
+	successes, failures := groupDeliveries(deliveries)
+
+	run := func(ctx context.Context, tx bun.Tx) error {
+		errMsg := "Failed to update subscription delivery metadata"
+
+		if len(successes) > 0 {
+			var hashes [][]byte
+
+			if _, err := tx.NewUpdate().
+				With("_data", tx.NewValues(&successes)).
+				Model((*PushSubscription)(nil)).
+				TableExpr("_data").
+				Set("last_status_code = _data.status_code").
+				Set("last_success_at = _data.at").
+				Set("consecutive_failures = 0").
+				Where("ps.endpoint_hash = _data.endpoint_hash").
+				Returning("ps.endpoint_hash").
+				Exec(ctx, &hashes); err != nil {
+				log.Printf("updating subscription delivery metadata failed: %v", err)
+				payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+				return errors.NewResponseError(payload, http.StatusInternalServerError)
+			}
+
+			// the subscriptions may have been deleted concurrently
+			if len(hashes) > 0 {
+				if _, err := tx.NewDelete().
+					Model((*DeliveryFailure)(nil)).
+					Where("subscription_hash IN (?)", bun.In(hashes)).
+					Exec(ctx); err != nil {
+					log.Printf("deleting subscription delivery failures failed: %v", err)
+					payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+					return errors.NewResponseError(payload, http.StatusInternalServerError)
+				}
+			}
+		}
+
+		if len(failures) == 0 {
+			return nil
+		}
+
+		var hashes [][]byte
+
+		if _, err := tx.NewUpdate().
+			With("_data", tx.NewValues(&failures)).
+			Model((*PushSubscription)(nil)).
+			TableExpr("_data").
+			Set("last_status_code = NULLIF(_data.status_code, 0)").
+			Set("last_failure_at = _data.at").
+			Set("consecutive_failures = ps.consecutive_failures + _data.failures").
+			Where("ps.endpoint_hash = _data.endpoint_hash").
+			Returning("ps.endpoint_hash").
+			Exec(ctx, &hashes); err != nil {
+			log.Printf("updating subscription delivery metadata failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		failuresByHash := make(map[string]*deliveryOutcome, len(failures))
+		for _, failure := range failures {
+			failuresByHash[string(failure.EndpointHash)] = failure
+		}
+
+		records := make([]*DeliveryFailure, 0, len(hashes))
+
+		for _, hash := range hashes {
+			for _, delivery := range failuresByHash[string(hash)].failed {
+				records = append(records, &DeliveryFailure{SubscriptionHash: hash, StatusCode: delivery.StatusCode, FailedAt: delivery.At})
+			}
+		}
+
+		if len(records) == 0 {
+			return nil
+		}
+
+		if _, err := tx.NewInsert().
+			Model(&records).
+			Exec(ctx); err != nil {
+			log.Printf("recording subscription delivery failures failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		return nil
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, run)
+	}
+
+	return
+}
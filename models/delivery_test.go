@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestDeliverySucceeded(t *testing.T) {
+	tests := map[int]bool{
+		0:                              false,
+		http.StatusOK:                  true,
+		http.StatusCreated:             true,
+		http.StatusNoContent:           true,
+		http.StatusGone:                false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+	}
+
+	for statusCode, want := range tests {
+		assert.Equal(t, NewDelivery("https://fcm.googleapis.com/fcm/send/1", statusCode).Succeeded(), want, "status code %d", statusCode)
+	}
+}
+
+func TestGroupDeliveries(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+
+	at := time.Now().UTC()
+	newDelivery := func(endpoint string, statusCode int, offset time.Duration) *Delivery {
+		return &Delivery{Endpoint: endpoint, StatusCode: statusCode, At: at.Add(offset)}
+	}
+
+	successes, failures := groupDeliveries([]*Delivery{
+		// only the failures following the last success count
+		newDelivery("https://fcm.googleapis.com/fcm/send/1", http.StatusGone, 3*time.Second),
+		newDelivery("https://fcm.googleapis.com/fcm/send/1", http.StatusCreated, 2*time.Second),
+		newDelivery("https://fcm.googleapis.com/fcm/send/1", http.StatusInternalServerError, time.Second),
+		newDelivery("https://fcm.googleapis.com/fcm/send/2", http.StatusCreated, time.Second),
+		newDelivery("https://fcm.googleapis.com/fcm/send/2", http.StatusTooManyRequests, 0),
+		newDelivery("https://fcm.googleapis.com/fcm/send/3", 0, 0),
+		newDelivery("https://fcm.googleapis.com/fcm/send/3", http.StatusTooManyRequests, time.Second),
+	})
+
+	assert.Equal(t, len(successes), 2)
+	assert.Equal(t, successes[0].StatusCode, http.StatusCreated)
+	assert.Equal(t, successes[0].At, at.Add(2*time.Second))
+	assert.Equal(t, successes[1].StatusCode, http.StatusCreated)
+
+	assert.Equal(t, len(failures), 2)
+	assert.Equal(t, failures[0].StatusCode, http.StatusGone)
+	assert.Equal(t, failures[0].Failures, 1)
+	assert.Equal(t, failures[1].StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, failures[1].At, at.Add(time.Second))
+	assert.Equal(t, failures[1].Failures, 2)
+
+	t.Setenv(utils.PREVIOUS_HMAC_SECRET_KEYS_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	successes, failures = groupDeliveries([]*Delivery{newDelivery("https://fcm.googleapis.com/fcm/send/1", http.StatusCreated, 0)})
+
+	// subscriptions not yet rehashed are matched by their previous hash
+	assert.Equal(t, len(successes), 2)
+	assert.Equal(t, len(failures), 0)
+}
+
+func TestSaveDeliveries(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	endpoint := "https://fcm.googleapis.com/fcm/send/1"
+	assert.NilError(t, newTestSubscription(t, "recipient-0", endpoint).Save(ctx, conn))
+
+	getSubscription := func() *PushSubscription {
+		subs, err := GetSubscriptionsByClientIdAndRecipientId(ctx, conn, TEST_CLIENT_ID, "recipient-0")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subs), 1)
+
+		return subs[0]
+	}
+
+	sub := getSubscription()
+	assert.Assert(t, sub.LastSuccessAt == nil)
+	assert.Assert(t, sub.LastFailureAt == nil)
+	assert.Equal(t, sub.ConsecutiveFailures, 0)
+
+	assert.NilError(t, SaveDeliveries(ctx, conn, []*Delivery{
+		NewDelivery(endpoint, http.StatusTooManyRequests),
+		NewDelivery(endpoint, 0),
+	}))
+
+	sub = getSubscription()
+	assert.Assert(t, sub.LastSuccessAt == nil)
+	assert.Assert(t, sub.LastFailureAt != nil)
+	assert.Equal(t, sub.LastStatusCode, 0)
+	assert.Equal(t, sub.ConsecutiveFailures, 2)
+
+	assert.NilError(t, SaveDeliveries(ctx, conn, []*Delivery{NewDelivery(endpoint, http.StatusCreated)}))
+
+	sub = getSubscription()
+	assert.Assert(t, sub.LastSuccessAt != nil)
+	assert.Equal(t, sub.LastStatusCode, http.StatusCreated)
+	assert.Equal(t, sub.ConsecutiveFailures, 0)
+}
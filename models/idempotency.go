@@ -0,0 +1,185 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const DEFAULT_IDEMPOTENCY_WINDOW int64 = 86400
+
+// DEFAULT_IDEMPOTENCY_LOCK_TIMEOUT is the amount of seconds a key is held by an incomplete request,
+// e.g. after the process timed out or crashed, before a retry may reclaim it.
+const DEFAULT_IDEMPOTENCY_LOCK_TIMEOUT int64 = 300
+
+type IdempotencyKey struct {
+	bun.BaseModel `bun:"table:webpush_idempotency_keys,alias:pik"`
+
+	ClientId    string    `json:"clientId" validate:"required" bun:"client_id,pk"`
+	Key         string    `json:"key" validate:"required,max=255,printascii" bun:"idempotency_key,pk"`
+	Fingerprint []byte    `json:"-" validate:"required,len=32" bun:"fingerprint,type:bytea,notnull"`
+	StatusCode  int       `json:"statusCode" bun:"status_code,notnull"`
+	ContentType string    `json:"contentType,omitempty" bun:"content_type,nullzero"`
+	Body        []byte    `json:"-" bun:"body,type:bytea"`
+	CreatedAt   time.Time `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	ExpiresAt   time.Time `json:"expiresAt" bun:"expires_at,notnull"`
+}
+
+func getSecondsEnv(name string, fallback int64) time.Duration {
+	env := os.Getenv(name)
+
+	if env == "" {
+		return time.Duration(fallback) * time.Second
+	}
+
+	seconds, err := strconv.ParseInt(env, 10, 64)
+
+	if err != nil || seconds <= 0 {
+		log.Printf("%s env must be a positive integer > 0, falling back to default: %d\n", name, fallback)
+		seconds = fallback
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// GetIdempotencyWindow returns the duration an idempotency key and its response are retained.
+func GetIdempotencyWindow() time.Duration {
+	return getSecondsEnv(utils.IDEMPOTENCY_WINDOW_ENV, DEFAULT_IDEMPOTENCY_WINDOW)
+}
+
+// GetIdempotencyLockTimeout returns the duration after which a key, whose request never completed, may be reclaimed.
+func GetIdempotencyLockTimeout() time.Duration {
+	return getSecondsEnv(utils.IDEMPOTENCY_LOCK_TIMEOUT_ENV, DEFAULT_IDEMPOTENCY_LOCK_TIMEOUT)
+}
+
+// InProgress reports whether the request holding the key has not completed yet.
+func (k *IdempotencyKey) InProgress() bool {
+	return k.StatusCode == 0
+}
+
+// Matches reports whether the key was stored for a request with the given fingerprint.
+func (k *IdempotencyKey) Matches(fingerprint []byte) bool {
+	return bytes.Equal(k.Fingerprint, fingerprint)
+}
+
+func (k *IdempotencyKey) Validate() (err error) {
+	if err = utils.CustomValidateStruct(k); err != nil {
+		log.Printf("invalid idempotency key: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid idempotency key", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+// Complete stores the response of the request holding the key, so that it may be replayed.
+func (k *IdempotencyKey) Complete(ctx context.Context, db bun.IDB, statusCode int, contentType string, body []byte) (err error) {
+	k.StatusCode = statusCode
+	k.ContentType = contentType
+	k.Body = body
+
+	if _, err = db.NewUpdate().
+		Model(k).
+		Column("status_code", "content_type", "body").
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("storing idempotency key response failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to store idempotency key", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+// Reserve stores the key for the current request, unless it has already been used within the idempotency window.
+// In that case, the existing key is returned instead. Keys of requests, which did not complete within the lock timeout, are reclaimed.
+func (k *IdempotencyKey) Reserve(ctx context.Context, db bun.IDB) (existing *IdempotencyKey, err error) {
+	if k.ExpiresAt.IsZero() {
+		k.ExpiresAt = time.Now().UTC().Add(GetIdempotencyWindow())
+	}
+
+	if err = k.Validate(); err != nil {
+		return
+	}
+
+	errMsg := "Failed to store idempotency key"
+	now := time.Now().UTC()
+
+	if _, err = db.NewDelete().
+		Model((*IdempotencyKey)(nil)).
+		Where("client_id = ?", k.ClientId).
+		Where("idempotency_key = ?", k.Key).
+		WhereGroup(" AND ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			return q.
+				Where("expires_at <= ?", now).
+				WhereOr("status_code = 0 AND created_at <= ?", now.Add(-GetIdempotencyLockTimeout()))
+		}).
+		Exec(ctx); err != nil {
+		log.Printf("deleting expired idempotency key failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	res, err := db.NewInsert().
+		Model(k).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("inserting idempotency key failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+		return nil, nil
+	}
+
+	existing = &IdempotencyKey{}
+
+	if err = db.NewSelect().
+		Model(existing).
+		Where("client_id = ?", k.ClientId).
+		Where("idempotency_key = ?", k.Key).
+		Scan(ctx); err != nil {
+		log.Printf("fetching idempotency key failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch idempotency key", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+// Release deletes the key, e.g. when its request could not be processed, so that it may be retried.
+func (k *IdempotencyKey) Release(ctx context.Context, db bun.IDB) (err error) {
+	if _, err = db.NewDelete().
+		Model(k).
+		WherePK().
+		Exec(ctx); err != nil {
+		log.Printf("deleting idempotency key failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete idempotency key", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func NewIdempotencyKey(clientId, key string, fingerprint []byte) *IdempotencyKey {
+	return &IdempotencyKey{
+		ClientId:    clientId,
+		Key:         key,
+		Fingerprint: fingerprint,
+	}
+}
+
+func (k IdempotencyKey) String() string {
+	return fmt.Sprintf("[Idempotency Key] %s (Client: %s)", k.Key, k.ClientId)
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+func TestGetIdempotencyWindow(t *testing.T) {
+	type testCase struct {
+		name string
+		env  string
+		want time.Duration
+	}
+
+	tests := []testCase{
+		{
+			name: "should fall back to default",
+			env:  "",
+			want: 24 * time.Hour,
+		},
+		{
+			name: "should parse window in seconds",
+			env:  "600",
+			want: 10 * time.Minute,
+		},
+		{
+			name: "should fall back to default on invalid window",
+			env:  "-1",
+			want: 24 * time.Hour,
+		},
+		{
+			name: "should fall back to default on malformed window",
+			env:  "1h",
+			want: 24 * time.Hour,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(utils.IDEMPOTENCY_WINDOW_ENV, tc.env)
+
+			if got := GetIdempotencyWindow(); got != tc.want {
+				t.Errorf("expected window %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyValidate(t *testing.T) {
+	fingerprint := make([]byte, 32)
+
+	type testCase struct {
+		name    string
+		key     *IdempotencyKey
+		wantErr bool
+	}
+
+	tests := []testCase{
+		{
+			name:    "should accept valid key",
+			key:     NewIdempotencyKey("client", "8e03978e-40d5-43e8-bc93-6894a57f9324", fingerprint),
+			wantErr: false,
+		},
+		{
+			name:    "should reject non-ASCII key",
+			key:     NewIdempotencyKey("client", "schlüssel", fingerprint),
+			wantErr: true,
+		},
+		{
+			name:    "should reject missing fingerprint",
+			key:     NewIdempotencyKey("client", "key", nil),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.key.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if !tc.key.Matches(tc.key.Fingerprint) || !tc.key.InProgress() {
+				t.Errorf("expected new key to match its own fingerprint and to be in progress")
+			}
+		})
+	}
+}
+
+func TestGetIdempotencyLockTimeout(t *testing.T) {
+	t.Setenv(utils.IDEMPOTENCY_LOCK_TIMEOUT_ENV, "")
+
+	if got := GetIdempotencyLockTimeout(); got != 5*time.Minute {
+		t.Errorf("expected lock timeout %v, got %v", 5*time.Minute, got)
+	}
+
+	t.Setenv(utils.IDEMPOTENCY_LOCK_TIMEOUT_ENV, "30")
+
+	if got := GetIdempotencyLockTimeout(); got != 30*time.Second {
+		t.Errorf("expected lock timeout %v, got %v", 30*time.Second, got)
+	}
+}
+
+func TestReserveReclaimsIncompleteKeys(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+
+	defer conn.Close()
+
+	fingerprint := make([]byte, 32)
+
+	key := NewIdempotencyKey(TEST_CLIENT_ID, "crashed", fingerprint)
+	if existing, err := key.Reserve(ctx, conn); err != nil || existing != nil {
+		t.Fatalf("expected key to be reserved, got existing = %v, err = %v", existing, err)
+	}
+
+	retry := NewIdempotencyKey(TEST_CLIENT_ID, "crashed", fingerprint)
+	if existing, err := retry.Reserve(ctx, conn); err != nil || existing == nil || !existing.InProgress() {
+		t.Fatalf("expected key to be in progress, got existing = %v, err = %v", existing, err)
+	}
+
+	// the request holding the key never completed
+	if _, err := conn.NewUpdate().
+		Model(key).
+		Set("created_at = ?", time.Now().Add(-GetIdempotencyLockTimeout()-time.Second)).
+		WherePK().
+		Exec(ctx); err != nil {
+		t.Fatalf("failed to backdate key: %v", err)
+	}
+
+	if existing, err := retry.Reserve(ctx, conn); err != nil || existing != nil {
+		t.Fatalf("expected incomplete key to be reclaimed, got existing = %v, err = %v", existing, err)
+	}
+}
@@ -0,0 +1,351 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const (
+	PRUNING_ACTION_DELETE     = "delete"
+	PRUNING_ACTION_QUARANTINE = "quarantine"
+)
+
+// PruningPolicy deletes or quarantines subscriptions of a client after MaxFailures failed deliveries within WindowDays days,
+// counting only failures since the last successful delivery, whose status code is contained in StatusCodes.
+// An empty StatusCodes matches any failure, the status code 0 matches unreachable push services.
+type PruningPolicy struct {
+	bun.BaseModel `bun:"table:webpush_pruning_policies,alias:pp"`
+
+	ClientId    string    `json:"-" bun:"client_id,pk"`
+	Action      string    `json:"action" validate:"oneof=delete quarantine" bun:"action,notnull"`
+	MaxFailures int       `json:"maxFailures" validate:"min=1,max=1000" bun:"max_failures,notnull"`
+	WindowDays  int       `json:"windowDays" validate:"min=1,max=365" bun:"window_days,notnull"`
+	StatusCodes []int     `json:"statusCodes" validate:"max=32,dive,min=0,max=599" bun:"status_codes,array,notnull"`
+	UpdatedAt   time.Time `json:"updatedAt,omitzero" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// ParsePruningPolicy parses a pruning policy in the format <action>:<failures>/<days>d[:<status codes>],
+// e.g. quarantine:5/7d:400,403,413 quarantines subscriptions after 5 failures with either status code within 7 days.
+func ParsePruningPolicy(s string) (policy *PruningPolicy, err error) {
+	parts := strings.SplitN(s, ":", 3)
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("pruning policy %q must be in the format <action>:<failures>/<days>d[:<status codes>], e.g. quarantine:5/7d:400,403,413", s)
+	}
+
+	policy = &PruningPolicy{Action: strings.TrimSpace(parts[0]), StatusCodes: []int{}}
+
+	failures, days, ok := strings.Cut(parts[1], "/")
+
+	if !ok {
+		return nil, fmt.Errorf("pruning policy %q must contain <failures>/<days>d, e.g. 5/7d", s)
+	}
+
+	if policy.MaxFailures, err = strconv.Atoi(strings.TrimSpace(failures)); err != nil {
+		return nil, fmt.Errorf("pruning policy %q must contain a number of failures", s)
+	}
+
+	if policy.WindowDays, err = strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(days), "d")); err != nil {
+		return nil, fmt.Errorf("pruning policy %q must contain a number of days, e.g. 7d", s)
+	}
+
+	if len(parts) == 3 {
+		for _, code := range strings.Split(parts[2], ",") {
+			statusCode, err := strconv.Atoi(strings.TrimSpace(code))
+			if err != nil {
+				return nil, fmt.Errorf("pruning policy %q contains an invalid status code %q", s, code)
+			}
+
+			policy.StatusCodes = append(policy.StatusCodes, statusCode)
+		}
+	}
+
+	if err = utils.CustomValidateStruct(policy); err != nil {
+		return nil, fmt.Errorf("pruning policy %q is invalid: %w", s, err)
+	}
+
+	return policy, nil
+}
+
+// GetDefaultPruningPolicy returns the pruning policy of the PRUNING_POLICY env for the given client.
+// A nil policy disables pruning.
+func GetDefaultPruningPolicy(clientId string) *PruningPolicy {
+	env := os.Getenv(utils.PRUNING_POLICY_ENV)
+
+	if env == "" {
+		return nil
+	}
+
+	policy, err := ParsePruningPolicy(env)
+	if err != nil {
+		log.Printf("failed to parse %s env, pruning is disabled: %v\n", utils.PRUNING_POLICY_ENV, err)
+		return nil
+	}
+
+	policy.ClientId = clientId
+
+	return policy
+}
+
+// GetPruningPolicy returns the pruning policy of a client, falling back to the PRUNING_POLICY env.
+// A nil policy disables pruning.
+func GetPruningPolicy(ctx context.Context, db bun.IDB, clientId string) (policy *PruningPolicy, err error) {
+	policy = &PruningPolicy{}
+
+	if err = db.NewSelect().
+		Model(policy).
+		Where("client_id = ?", clientId).
+		Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			return GetDefaultPruningPolicy(clientId), nil
+		}
+
+		log.Printf("fetching pruning policy failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch pruning policy", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func (p *PruningPolicy) Save(ctx context.Context, db bun.IDB) (err error) {
+	if err = p.Validate(); err != nil {
+		return
+	}
+
+	if p.StatusCodes == nil {
+		p.StatusCodes = []int{}
+	}
+
+	p.UpdatedAt = time.Now().UTC()
+
+	if _, err = db.NewInsert().
+		Model(p).
+		On("CONFLICT (client_id) DO UPDATE").
+		Set("action = EXCLUDED.action").
+		Set("max_failures = EXCLUDED.max_failures").
+		Set("window_days = EXCLUDED.window_days").
+		Set("status_codes = EXCLUDED.status_codes").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx); err != nil {
+		log.Printf("inserting pruning policy failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to store pruning policy", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func (p PruningPolicy) Validate() (err error) {
+	if p.ClientId == "" {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid pruning policy", "client ID is required")
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if err = utils.CustomValidateStruct(p); err != nil {
+		log.Printf("invalid pruning policy: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid pruning policy", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+// Matches reports whether a delivery with the given status code counts as failure towards the policy.
+func (p *PruningPolicy) Matches(statusCode int) bool {
+	if statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices {
+		return false
+	}
+
+	return len(p.StatusCodes) == 0 || slices.Contains(p.StatusCodes, statusCode)
+}
+
+func (p *PruningPolicy) Window() time.Duration {
+	return time.Duration(p.WindowDays) * 24 * time.Hour
+}
+
+func DeletePruningPolicy(ctx context.Context, db bun.IDB, clientId string) (err error) {
+	if _, err = db.NewDelete().
+		Model((*PruningPolicy)(nil)).
+		Where("client_id = ?", clientId).
+		Exec(ctx); err != nil {
+		log.Printf("deleting pruning policy failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete pruning policy", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+// ApplyPruningPolicy deletes or quarantines the failed subscriptions among the given deliveries, which exceed the pruning policy of the client.
+// It must run after the deliveries were saved, and returns the amount of pruned subscriptions.
+func ApplyPruningPolicy(ctx context.Context, db bun.IDB, clientId string, deliveries []*Delivery) (pruned int64, err error) {
+	var policy *PruningPolicy
+
+	if policy, err = GetPruningPolicy(ctx, db, clientId); err != nil || policy == nil {
+		return
+	}
+
+	candidates := make([][]byte, 0)
+	seen := make(map[string]bool)
+
+	for _, delivery := range deliveries {
+		if !policy.Matches(delivery.StatusCode) || seen[delivery.Endpoint] {
+			continue
+		}
+
+		seen[delivery.Endpoint] = true
+		candidates = append(candidates, utils.Hashes([]byte(delivery.Endpoint))...)
+	}
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	errMsg := "Failed to apply pruning policy"
+
+	run := func(ctx context.Context, tx bun.Tx) error {
+		since := time.Now().UTC().Add(-policy.Window())
+
+		// failures outside of the window never count again
+		if _, err := tx.NewDelete().
+			Model((*DeliveryFailure)(nil)).
+			Where("subscription_hash IN (?)", bun.In(candidates)).
+			Where("failed_at < ?", since).
+			Exec(ctx); err != nil {
+			log.Printf("deleting outdated delivery failures failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		query := tx.NewSelect().
+			Model((*DeliveryFailure)(nil)).
+			Column("subscription_hash").
+			Where("subscription_hash IN (?)", bun.In(candidates)).
+			Group("subscription_hash").
+			Having("count(*) >= ?", policy.MaxFailures)
+
+		if len(policy.StatusCodes) > 0 {
+			query = query.Where("status_code IN (?)", bun.In(policy.StatusCodes))
+		}
+
+		exceeded := make([][]byte, 0)
+
+		if err := query.Scan(ctx, &exceeded); err != nil {
+			log.Printf("counting delivery failures failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		if len(exceeded) == 0 {
+			return nil
+		}
+
+		var res sql.Result
+		var err error
+
+		switch policy.Action {
+		case PRUNING_ACTION_DELETE:
+			res, err = tx.NewDelete().
+				Model((*PushSubscription)(nil)).
+				Where("client_id = ?", clientId).
+				Where("endpoint_hash IN (?)", bun.In(exceeded)).
+				Exec(ctx)
+		default:
+			res, err = tx.NewUpdate().
+				Model((*PushSubscription)(nil)).
+				Set("quarantined_at = ?", time.Now().UTC()).
+				Where("client_id = ?", clientId).
+				Where("endpoint_hash IN (?)", bun.In(exceeded)).
+				Where("quarantined_at IS NULL").
+				Exec(ctx)
+		}
+
+		if err != nil {
+			log.Printf("pruning subscriptions failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		pruned, _ = res.RowsAffected()
+
+		log.Printf("pruning policy of client: %s applied, %d subscriptions affected by %s\n", clientId, pruned, policy.Action)
+
+		return nil
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, run)
+	}
+
+	return
+}
+
+// RestoreSubscription lifts the quarantine of a subscription, its recorded delivery failures are discarded.
+func RestoreSubscription(ctx context.Context, db bun.IDB, clientId, hash string) (subscription *PushSubscription, err error) {
+	var decoded []byte
+
+	if decoded, err = decodeSubscriptionHash(hash); err != nil {
+		return
+	}
+
+	errMsg := "Failed to restore subscription"
+
+	run := func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewUpdate().
+			Model((*PushSubscription)(nil)).
+			Set("quarantined_at = NULL").
+			Set("consecutive_failures = 0").
+			Where("client_id = ?", clientId).
+			Where("endpoint_hash = ?", decoded).
+			Where("quarantined_at IS NOT NULL").
+			Exec(ctx)
+		if err != nil {
+			log.Printf("restoring subscription failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			payload := errors.NewErrorResponse(http.StatusNotFound, "Quarantined subscription not found")
+			return errors.NewResponseError(payload, http.StatusNotFound)
+		}
+
+		if _, err = tx.NewDelete().
+			Model((*DeliveryFailure)(nil)).
+			Where("subscription_hash = ?", decoded).
+			Exec(ctx); err != nil {
+			log.Printf("deleting delivery failures of restored subscription failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		return nil
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, run)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return GetSubscriptionByClientIdAndHash(ctx, db, clientId, hash)
+}
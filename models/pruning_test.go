@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestParsePruningPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *PruningPolicy
+		wantErr bool
+	}{
+		{"should parse policy with status codes", "quarantine:5/7d:400,403,413", &PruningPolicy{Action: PRUNING_ACTION_QUARANTINE, MaxFailures: 5, WindowDays: 7, StatusCodes: []int{400, 403, 413}}, false},
+		{"should parse policy without status codes", "delete:10/30d", &PruningPolicy{Action: PRUNING_ACTION_DELETE, MaxFailures: 10, WindowDays: 30, StatusCodes: []int{}}, false},
+		{"should reject unknown actions", "archive:5/7d", nil, true},
+		{"should reject missing window", "delete:5", nil, true},
+		{"should reject zero failures", "delete:0/7d", nil, true},
+		{"should reject invalid status codes", "delete:5/7d:4xx", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePruningPolicy(tt.value)
+
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.DeepEqual(t, policy, tt.want)
+		})
+	}
+}
+
+func TestPruningPolicyMatches(t *testing.T) {
+	anyFailure := &PruningPolicy{}
+	some := &PruningPolicy{StatusCodes: []int{0, http.StatusForbidden}}
+
+	assert.Assert(t, !anyFailure.Matches(http.StatusCreated))
+	assert.Assert(t, anyFailure.Matches(http.StatusBadRequest))
+	assert.Assert(t, anyFailure.Matches(0))
+	assert.Assert(t, !some.Matches(http.StatusBadRequest))
+	assert.Assert(t, some.Matches(http.StatusForbidden))
+	assert.Assert(t, some.Matches(0))
+}
+
+func TestApplyPruningPolicy(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+	t.Setenv(utils.PRUNING_POLICY_ENV, "delete:2/7d")
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	policy := &PruningPolicy{ClientId: TEST_CLIENT_ID, Action: PRUNING_ACTION_QUARANTINE, MaxFailures: 2, WindowDays: 7, StatusCodes: []int{http.StatusForbidden}}
+	assert.NilError(t, policy.Save(ctx, conn))
+
+	stored, err := GetPruningPolicy(ctx, conn, TEST_CLIENT_ID)
+	assert.NilError(t, err)
+	assert.Equal(t, stored.Action, PRUNING_ACTION_QUARANTINE)
+	assert.DeepEqual(t, stored.StatusCodes, []int{http.StatusForbidden})
+
+	fallback, err := GetPruningPolicy(ctx, conn, "other client")
+	assert.NilError(t, err)
+	assert.Equal(t, fallback.Action, PRUNING_ACTION_DELETE)
+
+	endpoint := "https://fcm.googleapis.com/fcm/send/1"
+	assert.NilError(t, newTestSubscription(t, "recipient-0", endpoint).Save(ctx, conn))
+
+	deliver := func(statusCode int) int64 {
+		deliveries := []*Delivery{NewDelivery(endpoint, statusCode)}
+
+		assert.NilError(t, SaveDeliveries(ctx, conn, deliveries))
+
+		pruned, err := ApplyPruningPolicy(ctx, conn, TEST_CLIENT_ID, deliveries)
+		assert.NilError(t, err)
+
+		return pruned
+	}
+
+	assert.Equal(t, deliver(http.StatusForbidden), int64(0))
+	assert.Equal(t, deliver(http.StatusCreated), int64(0), "a successful delivery resets the failures")
+	assert.Equal(t, deliver(http.StatusForbidden), int64(0))
+	assert.Equal(t, deliver(http.StatusBadRequest), int64(0), "other status codes are not counted")
+	assert.Equal(t, deliver(http.StatusForbidden), int64(1))
+
+	subs, err := GetSubscriptionsByClientIdAndRecipientId(ctx, conn, TEST_CLIENT_ID, "recipient-0")
+	assert.NilError(t, err)
+	assert.Equal(t, len(subs), 0, "quarantined subscriptions are excluded from sends")
+
+	list, _, err := ListSubscriptions(ctx, conn, TEST_CLIENT_ID, &SubscriptionFilter{Quarantined: &[]bool{true}[0]})
+	assert.NilError(t, err)
+	assert.Equal(t, len(list), 1)
+	assert.Assert(t, list[0].QuarantinedAt != nil)
+
+	restored, err := RestoreSubscription(ctx, conn, TEST_CLIENT_ID, list[0].Hash.String())
+	assert.NilError(t, err)
+	assert.Assert(t, restored.QuarantinedAt == nil)
+
+	assert.Equal(t, deliver(http.StatusForbidden), int64(0), "restoring discards the recorded failures")
+}
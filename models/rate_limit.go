@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/uptrace/bun"
+)
+
+// RateLimit allows bursts of up to Requests requests, refilling at a rate of Requests per Window.
+type RateLimit struct {
+	Requests int64
+	Window   time.Duration
+}
+
+func (l *RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type RateLimitBucket struct {
+	bun.BaseModel `bun:"table:webpush_rate_limits,alias:prl"`
+
+	Key       string    `json:"key" bun:"bucket_key,pk"`
+	Tokens    float64   `json:"tokens" bun:"tokens,notnull"`
+	UpdatedAt time.Time `json:"updatedAt" bun:"updated_at,nullzero"`
+}
+
+// Take refills the token bucket according to the time passed since its last update and takes a single token, if available.
+func (b *RateLimitBucket) Take(limit *RateLimit, now time.Time) (result *RateLimitResult) {
+	capacity := float64(limit.Requests)
+	rate := limit.rate()
+
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+
+	b.UpdatedAt = now
+
+	result = &RateLimitResult{
+		Allowed: b.Tokens >= 1,
+		Limit:   limit.Requests,
+	}
+
+	if result.Allowed {
+		b.Tokens--
+	} else {
+		result.RetryAfter = time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+	}
+
+	result.Remaining = int64(math.Floor(b.Tokens))
+	result.Reset = time.Duration((capacity - b.Tokens) / rate * float64(time.Second))
+
+	return
+}
+
+// TakeRateLimitToken takes a token from the bucket stored for the given key, locking it for concurrent requests.
+func TakeRateLimitToken(ctx context.Context, db bun.IDB, key string, limit *RateLimit) (result *RateLimitResult, err error) {
+	errMsg := "Failed to update rate limit"
+
+	run := func(ctx context.Context, tx bun.Tx) error {
+		bucket := &RateLimitBucket{Key: key}
+
+		if _, err := tx.NewInsert().
+			Model(bucket).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx); err != nil {
+			return err
+		}
+
+		if err := tx.NewSelect().
+			Model(bucket).
+			WherePK().
+			For("UPDATE").
+			Scan(ctx); err != nil {
+			return err
+		}
+
+		result = bucket.Take(limit, time.Now().UTC())
+
+		_, err := tx.NewUpdate().
+			Model(bucket).
+			Column("tokens", "updated_at").
+			WherePK().
+			Exec(ctx)
+
+		return err
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, &sql.TxOptions{}, run)
+	}
+
+	if err != nil {
+		log.Printf("taking rate limit token failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+type QuotaUsage struct {
+	bun.BaseModel `bun:"table:webpush_quota_usage,alias:pqu"`
+
+	ClientId  string `json:"clientId" bun:"client_id,pk"`
+	Period    string `json:"period" bun:"period,pk"`
+	Delivered int64  `json:"delivered" bun:"delivered,notnull"`
+}
+
+func GetQuotaUsage(ctx context.Context, db bun.IDB, clientId, period string) (delivered int64, err error) {
+	usage := &QuotaUsage{}
+
+	if err = db.NewSelect().
+		Model(usage).
+		Where("client_id = ?", clientId).
+		Where("period = ?", period).
+		Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		log.Printf("fetching quota usage failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch quota usage", err.Error())
+		return 0, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return usage.Delivered, nil
+}
+
+func AddQuotaUsage(ctx context.Context, db bun.IDB, clientId, period string, delivered int64) (err error) {
+	usage := &QuotaUsage{
+		ClientId:  clientId,
+		Period:    period,
+		Delivered: delivered,
+	}
+
+	if _, err = db.NewInsert().
+		Model(usage).
+		On("CONFLICT (client_id, period) DO UPDATE").
+		Set("delivered = pqu.delivered + EXCLUDED.delivered").
+		Exec(ctx); err != nil {
+		log.Printf("updating quota usage failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to update quota usage", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/provider"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const DEFAULT_REENCRYPT_BATCH_SIZE = 500
+
+// ReencryptResult holds the amount of rows re-encrypted with the active master key,
+// as well as the amount of subscriptions classified by their push service, which were stored before push services were.
+type ReencryptResult struct {
+	Subscriptions int64 `json:"subscriptions"`
+	Keys          int64 `json:"keys"`
+	PushServices  int64 `json:"pushServices"`
+}
+
+// encryptedSubscription and encryptedKeys select the encrypted columns as stored, bypassing the decryption of utils.EncryptedBytes.
+type encryptedSubscription struct {
+	bun.BaseModel `bun:"table:webpush_subscriptions,alias:ps"`
+
+	EndpointHash []byte `bun:"endpoint_hash,pk"`
+	Endpoint     []byte `bun:"endpoint"`
+	PushService  string `bun:"push_service"`
+}
+
+type encryptedKeys struct {
+	bun.BaseModel `bun:"table:webpush_keys,alias:pk"`
+
+	Hash       []byte `bun:"p256dh_hash,pk"`
+	P256DH     []byte `bun:"p256dh"`
+	AuthSecret []byte `bun:"auth_secret"`
+}
+
+// Reencrypt seals every encrypted column, which is not yet sealed by the active master key, again with the active master key.
+// The rows are processed in batches of the given size, each in its own transaction, so an interrupted run may simply be restarted.
+func Reencrypt(ctx context.Context, db bun.IDB, batchSize int) (result *ReencryptResult, err error) {
+	if batchSize <= 0 {
+		batchSize = DEFAULT_REENCRYPT_BATCH_SIZE
+	}
+
+	result = &ReencryptResult{}
+
+	if result.Subscriptions, result.PushServices, err = reencryptSubscriptions(ctx, db, batchSize); err != nil {
+		return nil, err
+	}
+
+	if result.Keys, err = reencryptKeys(ctx, db, batchSize); err != nil {
+		return nil, err
+	}
+
+	log.Printf("re-encrypted %d subscriptions and %d keys with the active master key, classified %d subscriptions by push service\n", result.Subscriptions, result.Keys, result.PushServices)
+
+	return
+}
+
+func reencryptSubscriptions(ctx context.Context, db bun.IDB, batchSize int) (count, classified int64, err error) {
+	var cursor []byte
+
+	for {
+		var rows []*encryptedSubscription
+
+		query := db.NewSelect().
+			Model(&rows).
+			Order("endpoint_hash ASC").
+			Limit(batchSize)
+
+		if cursor != nil {
+			query = query.Where("endpoint_hash > ?", cursor)
+		}
+
+		if err = query.Scan(ctx); err != nil {
+			return count, classified, newReencryptError("selecting encrypted subscriptions failed", err)
+		}
+
+		if len(rows) == 0 {
+			return
+		}
+
+		cursor = rows[len(rows)-1].EndpointHash
+
+		run := func(ctx context.Context, tx bun.Tx) error {
+			for _, row := range rows {
+				columns := make([]string, 0, 2)
+
+				changed, err := reencryptColumns(&row.Endpoint)
+				if err != nil {
+					return newReencryptError("re-encrypting subscription endpoint failed", err)
+				}
+
+				if changed {
+					columns = append(columns, "endpoint")
+				}
+
+				reclassified := false
+
+				// subscriptions stored before the push_service column existed default to unknown
+				if row.PushService == provider.UNKNOWN {
+					endpoint, err := utils.Decrypt(row.Endpoint)
+					if err != nil {
+						return newReencryptError("decrypting subscription endpoint failed", err)
+					}
+
+					if pushService := provider.ForEndpoint(string(endpoint)).Name; pushService != provider.UNKNOWN {
+						row.PushService = pushService
+						columns = append(columns, "push_service")
+						reclassified = true
+					}
+				}
+
+				if len(columns) == 0 {
+					continue
+				}
+
+				if _, err = tx.NewUpdate().Model(row).Column(columns...).WherePK().Exec(ctx); err != nil {
+					return newReencryptError("updating encrypted subscription failed", err)
+				}
+
+				if changed {
+					count++
+				}
+
+				if reclassified {
+					classified++
+				}
+			}
+
+			return nil
+		}
+
+		if tx, ok := db.(bun.Tx); ok {
+			err = run(ctx, tx)
+		} else {
+			err = db.RunInTx(ctx, nil, run)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func reencryptKeys(ctx context.Context, db bun.IDB, batchSize int) (count int64, err error) {
+	var cursor []byte
+
+	for {
+		var rows []*encryptedKeys
+
+		query := db.NewSelect().
+			Model(&rows).
+			Order("p256dh_hash ASC").
+			Limit(batchSize)
+
+		if cursor != nil {
+			query = query.Where("p256dh_hash > ?", cursor)
+		}
+
+		if err = query.Scan(ctx); err != nil {
+			return count, newReencryptError("selecting encrypted subscription keys failed", err)
+		}
+
+		if len(rows) == 0 {
+			return
+		}
+
+		cursor = rows[len(rows)-1].Hash
+
+		run := func(ctx context.Context, tx bun.Tx) error {
+			for _, row := range rows {
+				changed, err := reencryptColumns(&row.P256DH, &row.AuthSecret)
+				if err != nil {
+					return newReencryptError("re-encrypting subscription keys failed", err)
+				}
+
+				if !changed {
+					continue
+				}
+
+				if _, err = tx.NewUpdate().Model(row).Column("p256dh", "auth_secret").WherePK().Exec(ctx); err != nil {
+					return newReencryptError("updating encrypted subscription keys failed", err)
+				}
+
+				count++
+			}
+
+			return nil
+		}
+
+		if tx, ok := db.(bun.Tx); ok {
+			err = run(ctx, tx)
+		} else {
+			err = db.RunInTx(ctx, nil, run)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// reencryptColumns re-encrypts the given ciphertexts in place, and reports whether any of them changed.
+func reencryptColumns(columns ...*[]byte) (changed bool, err error) {
+	for _, column := range columns {
+		needsReencryption, err := utils.NeedsReencryption(*column)
+		if err != nil {
+			return false, err
+		}
+
+		if !needsReencryption {
+			continue
+		}
+
+		if *column, err = utils.Reencrypt(*column); err != nil {
+			return false, err
+		}
+
+		changed = true
+	}
+
+	return
+}
+
+func newReencryptError(msg string, err error) error {
+	log.Printf("%s: %v", msg, err)
+	payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to re-encrypt subscription data", err.Error())
+	return errors.NewResponseError(payload, http.StatusInternalServerError)
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	"github.com/saschazar21/go-web-push-server/provider"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestReencrypt(t *testing.T) {
+	oldKey := "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI="
+	newKey := "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc="
+
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, oldKey)
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	for i := range 3 {
+		endpoint := fmt.Sprintf("https://fcm.googleapis.com/fcm/send/reencrypt-%d", i)
+		assert.NilError(t, newTestSubscription(t, "recipient-0", endpoint).Save(ctx, conn))
+	}
+
+	// subscriptions stored before the push_service column existed
+	_, err = conn.NewUpdate().
+		Model((*PushSubscription)(nil)).
+		Set("push_service = ?", provider.UNKNOWN).
+		Where("TRUE").
+		Exec(ctx)
+	assert.NilError(t, err)
+
+	t.Setenv(utils.MASTER_KEY_ENV, newKey)
+	t.Setenv(utils.PREVIOUS_MASTER_KEYS_ENV, oldKey)
+
+	result, err := Reencrypt(ctx, conn, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, result.Subscriptions, int64(3))
+	assert.Equal(t, result.Keys, int64(3))
+	assert.Equal(t, result.PushServices, int64(3))
+
+	result, err = Reencrypt(ctx, conn, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, result.Subscriptions, int64(0))
+	assert.Equal(t, result.Keys, int64(0))
+	assert.Equal(t, result.PushServices, int64(0))
+
+	// the old key is no longer required once all rows are re-encrypted
+	t.Setenv(utils.PREVIOUS_MASTER_KEYS_ENV, "")
+
+	subscriptions, err := GetSubscriptionsByClientId(ctx, conn, TEST_CLIENT_ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(subscriptions), 3)
+
+	for _, subscription := range subscriptions {
+		assert.Equal(t, subscription.PushService, provider.FCM)
+		assert.Assert(t, len(*subscription.Keys.P256DH) == 65)
+		assert.Assert(t, len(*subscription.Keys.AuthSecret) == 16)
+	}
+}
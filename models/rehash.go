@@ -0,0 +1,262 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const DEFAULT_REHASH_BATCH_SIZE = 500
+
+// RehashResult holds the amount of rows, whose hashes were rewritten using the active HMAC_SECRET_KEY.
+type RehashResult struct {
+	Subscriptions int64 `json:"subscriptions"`
+	Keys          int64 `json:"keys"`
+}
+
+// hashedSubscription and hashedKeys select the hash columns as stored, bypassing the base64url-encoding of utils.HashedString.
+type hashedSubscription struct {
+	bun.BaseModel `bun:"table:webpush_subscriptions,alias:ps"`
+
+	EndpointHash []byte                 `bun:"endpoint_hash,pk"`
+	Endpoint     *utils.EncryptedString `bun:"endpoint"`
+}
+
+type hashedKeys struct {
+	bun.BaseModel `bun:"table:webpush_keys,alias:pk"`
+
+	Hash           []byte                `bun:"p256dh_hash,pk"`
+	P256DH         *utils.EncryptedBytes `bun:"p256dh"`
+	AuthSecretHash []byte                `bun:"auth_secret_hash"`
+	AuthSecret     *utils.EncryptedBytes `bun:"auth_secret"`
+}
+
+// Rehash decrypts the stored endpoints and keys, and rewrites their hashes using the active HMAC_SECRET_KEY, e.g. after rotating the secret.
+// The rows are processed in batches of the given size, each in its own transaction, so an interrupted run may simply be restarted.
+func Rehash(ctx context.Context, db bun.IDB, batchSize int) (result *RehashResult, err error) {
+	if batchSize <= 0 {
+		batchSize = DEFAULT_REHASH_BATCH_SIZE
+	}
+
+	result = &RehashResult{}
+
+	if result.Subscriptions, err = rehashSubscriptions(ctx, db, batchSize); err != nil {
+		return nil, err
+	}
+
+	if result.Keys, err = rehashKeys(ctx, db, batchSize); err != nil {
+		return nil, err
+	}
+
+	log.Printf("rehashed %d subscriptions and %d keys with the active HMAC secret\n", result.Subscriptions, result.Keys)
+
+	return
+}
+
+func rehashSubscriptions(ctx context.Context, db bun.IDB, batchSize int) (count int64, err error) {
+	var cursor []byte
+
+	for {
+		var rows []*hashedSubscription
+
+		query := db.NewSelect().
+			Model(&rows).
+			Order("endpoint_hash ASC").
+			Limit(batchSize)
+
+		if cursor != nil {
+			query = query.Where("endpoint_hash > ?", cursor)
+		}
+
+		if err = query.Scan(ctx); err != nil {
+			return count, newRehashError("selecting hashed subscriptions failed", err)
+		}
+
+		if len(rows) == 0 {
+			return
+		}
+
+		cursor = rows[len(rows)-1].EndpointHash
+
+		run := func(ctx context.Context, tx bun.Tx) error {
+			for _, row := range rows {
+				hash := utils.Hash([]byte(*row.Endpoint))
+
+				// rehashed rows may be visited again, when their new hash sorts after the cursor
+				if bytes.Equal(hash[:], row.EndpointHash) {
+					continue
+				}
+
+				if err := moveSubscription(ctx, tx, row.EndpointHash, hash[:]); err != nil {
+					return err
+				}
+
+				count++
+			}
+
+			return nil
+		}
+
+		if tx, ok := db.(bun.Tx); ok {
+			err = run(ctx, tx)
+		} else {
+			err = db.RunInTx(ctx, nil, run)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func rehashKeys(ctx context.Context, db bun.IDB, batchSize int) (count int64, err error) {
+	var cursor []byte
+
+	for {
+		var rows []*hashedKeys
+
+		query := db.NewSelect().
+			Model(&rows).
+			Order("p256dh_hash ASC").
+			Limit(batchSize)
+
+		if cursor != nil {
+			query = query.Where("p256dh_hash > ?", cursor)
+		}
+
+		if err = query.Scan(ctx); err != nil {
+			return count, newRehashError("selecting hashed subscription keys failed", err)
+		}
+
+		if len(rows) == 0 {
+			return
+		}
+
+		cursor = rows[len(rows)-1].Hash
+
+		run := func(ctx context.Context, tx bun.Tx) error {
+			for _, row := range rows {
+				p256dhHash := utils.Hash(*row.P256DH)
+				authSecretHash := utils.Hash(*row.AuthSecret)
+
+				if bytes.Equal(p256dhHash[:], row.Hash) && bytes.Equal(authSecretHash[:], row.AuthSecretHash) {
+					continue
+				}
+
+				// no other table references the keys, so their primary key may be updated in place
+				if _, err := tx.NewUpdate().
+					Model((*SubscriptionKeys)(nil)).
+					Set("p256dh_hash = ?", p256dhHash[:]).
+					Set("auth_secret_hash = ?", authSecretHash[:]).
+					Where("p256dh_hash = ?", row.Hash).
+					Exec(ctx); err != nil {
+					return newRehashError("updating subscription key hashes failed", err)
+				}
+
+				count++
+			}
+
+			return nil
+		}
+
+		if tx, ok := db.(bun.Tx); ok {
+			err = run(ctx, tx)
+		} else {
+			err = db.RunInTx(ctx, nil, run)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// rehashPreviousSubscription moves a subscription stored under a hash of a previous HMAC secret to the hash of the active secret,
+// which keeps upserts from storing the same endpoint twice during a rotation of the secret.
+func rehashPreviousSubscription(ctx context.Context, tx bun.Tx, endpoint string) (err error) {
+	hashes := utils.Hashes([]byte(endpoint))
+
+	if len(hashes) < 2 {
+		return
+	}
+
+	var previous [][]byte
+
+	if err = tx.NewSelect().
+		Model((*PushSubscription)(nil)).
+		Column("endpoint_hash").
+		Where("endpoint_hash IN (?)", bun.In(hashes[1:])).
+		Scan(ctx, &previous); err != nil {
+		return newRehashError("selecting subscriptions of previous HMAC secrets failed", err)
+	}
+
+	for _, hash := range previous {
+		if err = moveSubscription(ctx, tx, hash, hashes[0]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// moveSubscription changes the primary key of a subscription, including the references of its keys, tags and delivery failures.
+// If a subscription with the new hash exists already, the subscription with the old hash is deleted instead.
+func moveSubscription(ctx context.Context, tx bun.Tx, from, to []byte) (err error) {
+	exists, err := tx.NewSelect().
+		Model((*PushSubscription)(nil)).
+		Where("endpoint_hash = ?", to).
+		Exists(ctx)
+	if err != nil {
+		return newRehashError("checking for rehashed subscription failed", err)
+	}
+
+	if !exists {
+		// the foreign keys don't cascade updates, hence the row is copied, its references updated, and the old row deleted
+		table := tx.Dialect().Tables().Get(reflect.TypeFor[PushSubscription]())
+
+		columns := make([]string, 0, len(table.DataFields))
+		for _, field := range table.DataFields {
+			columns = append(columns, string(field.SQLName))
+		}
+
+		if _, err = tx.NewRaw(
+			"INSERT INTO ? (?, ?) SELECT ?, ? FROM ? WHERE ? = ?",
+			table.SQLName, table.PKs[0].SQLName, bun.Safe(strings.Join(columns, ", ")),
+			to, bun.Safe(strings.Join(columns, ", ")), table.SQLName, table.PKs[0].SQLName, from,
+		).Exec(ctx); err != nil {
+			return newRehashError("copying rehashed subscription failed", err)
+		}
+
+		for _, model := range []any{(*SubscriptionKeys)(nil), (*SubscriptionTag)(nil), (*DeliveryFailure)(nil)} {
+			if _, err = tx.NewUpdate().
+				Model(model).
+				Set("subscription_hash = ?", to).
+				Where("subscription_hash = ?", from).
+				Exec(ctx); err != nil {
+				return newRehashError("updating references of rehashed subscription failed", err)
+			}
+		}
+	}
+
+	if _, err = tx.NewDelete().
+		Model((*PushSubscription)(nil)).
+		Where("endpoint_hash = ?", from).
+		Exec(ctx); err != nil {
+		return newRehashError("deleting previously hashed subscription failed", err)
+	}
+
+	return
+}
+
+func newRehashError(msg string, err error) error {
+	log.Printf("%s: %v", msg, err)
+	payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to rehash subscription data", err.Error())
+	return errors.NewResponseError(payload, http.StatusInternalServerError)
+}
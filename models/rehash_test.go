@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestRehash(t *testing.T) {
+	oldSecret := "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI="
+	newSecret := "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc="
+
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, oldSecret)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	for i := range 3 {
+		sub := newTestSubscription(t, "recipient-0", fmt.Sprintf("https://fcm.googleapis.com/fcm/send/rehash-%d", i))
+		sub.Tags = []string{"news"}
+		assert.NilError(t, sub.Save(ctx, conn))
+	}
+
+	assert.NilError(t, SaveDeliveries(ctx, conn, []*Delivery{NewDelivery("https://fcm.googleapis.com/fcm/send/rehash-0", 403)}))
+
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, newSecret)
+	t.Setenv(utils.PREVIOUS_HMAC_SECRET_KEYS_ENV, oldSecret)
+
+	t.Run("matches previous hashes during rotation", func(t *testing.T) {
+		assert.NilError(t, SaveDeliveries(ctx, conn, []*Delivery{NewDelivery("https://fcm.googleapis.com/fcm/send/rehash-0", 403)}))
+
+		failures, err := conn.NewSelect().Model((*DeliveryFailure)(nil)).Count(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, failures, 2)
+	})
+
+	t.Run("moves a resubscribed subscription to the active hash", func(t *testing.T) {
+		sub := newTestSubscription(t, "recipient-0", "https://fcm.googleapis.com/fcm/send/rehash-1")
+		assert.NilError(t, sub.Save(ctx, conn))
+
+		count, err := conn.NewSelect().Model((*PushSubscription)(nil)).Count(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 3)
+	})
+
+	t.Run("rehashes the remaining subscriptions", func(t *testing.T) {
+		result, err := Rehash(ctx, conn, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, result.Subscriptions, int64(2))
+		assert.Equal(t, result.Keys, int64(2))
+
+		result, err = Rehash(ctx, conn, 2)
+		assert.NilError(t, err)
+		assert.Equal(t, result.Subscriptions, int64(0))
+		assert.Equal(t, result.Keys, int64(0))
+	})
+
+	t.Run("keeps tags and delivery failures of rehashed subscriptions", func(t *testing.T) {
+		t.Setenv(utils.PREVIOUS_HMAC_SECRET_KEYS_ENV, "")
+
+		hash := utils.Hash([]byte("https://fcm.googleapis.com/fcm/send/rehash-0"))
+
+		failures, err := conn.NewSelect().Model((*DeliveryFailure)(nil)).Where("subscription_hash = ?", hash[:]).Count(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, failures, 2)
+
+		tags, err := conn.NewSelect().Model((*SubscriptionTag)(nil)).Where("subscription_hash = ?", hash[:]).Count(ctx)
+		assert.NilError(t, err)
+		assert.Equal(t, tags, 1)
+
+		subscriptions, err := GetSubscriptionsByClientIdAndRecipientId(ctx, conn, TEST_CLIENT_ID, "recipient-0")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 3)
+	})
+}
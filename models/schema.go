@@ -0,0 +1,18 @@
+package models
+
+import "github.com/saschazar21/go-web-push-server/db"
+
+// the tables and columns of the models are checked against the database, when connecting for the first time
+func init() {
+	db.RegisterModels(
+		(*PushSubscription)(nil),
+		(*SubscriptionKeys)(nil),
+		(*SubscriptionTag)(nil),
+		(*DeliveryFailure)(nil),
+		(*PruningPolicy)(nil),
+		(*NotificationTemplate)(nil),
+		(*IdempotencyKey)(nil),
+		(*RateLimitBucket)(nil),
+		(*QuotaUsage)(nil),
+	)
+}
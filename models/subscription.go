@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/provider"
 	"github.com/saschazar21/go-web-push-server/utils"
 	"github.com/uptrace/bun"
 )
@@ -21,6 +22,17 @@ type PushSubscription struct {
 	ClientId       string                 `json:"clientId" validate:"required" bun:"client_id,notnull"`
 	RecipientId    string                 `json:"recipientId" validate:"required" bun:"recipient_id,notnull"`
 	ExpirationTime *utils.EpochMillis     `json:"expirationTime,omitempty" validate:"omitempty,epoch-gt-now" bun:"expiration_time"`
+	Locale         string                 `json:"locale" validate:"omitempty,bcp47_language_tag" bun:"locale,nullzero,notnull,default:'en'"`
+	PushService    string                 `json:"pushService" bun:"push_service,nullzero,notnull,default:'unknown'"`
+	Tags           []string               `json:"tags,omitempty" validate:"omitempty,max=32,dive,tag" bun:"-"`
+	CreatedAt      time.Time              `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt      time.Time              `json:"updatedAt" bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty" bun:"last_success_at"`
+	LastFailureAt       *time.Time `json:"lastFailureAt,omitempty" bun:"last_failure_at"`
+	LastStatusCode      int        `json:"lastStatusCode,omitempty" bun:"last_status_code,nullzero"`
+	ConsecutiveFailures int        `json:"consecutiveFailures" bun:"consecutive_failures,notnull,default:0"`
+	QuarantinedAt       *time.Time `json:"quarantinedAt,omitempty" bun:"quarantined_at"`
 
 	Keys *SubscriptionKeys `validate:"-" bun:"rel:has-one,join:endpoint_hash=subscription_hash"`
 }
@@ -31,6 +43,7 @@ func (s *PushSubscription) BeforeAppendModel(ctx context.Context, query bun.Quer
 	if s.Endpoint != nil {
 		endpointHash := utils.HashedString(*s.Endpoint)
 		s.Hash = &endpointHash
+		s.PushService = provider.ForEndpoint(string(*s.Endpoint)).Name
 	}
 	return nil
 }
@@ -54,6 +67,12 @@ func (s *PushSubscription) Save(ctx context.Context, db bun.IDB) (err error) {
 	errMsg := "Failed to store subscription in database"
 
 	run := func(ctx context.Context, db bun.Tx) error {
+		if s.Endpoint != nil {
+			if err := rehashPreviousSubscription(ctx, db, string(*s.Endpoint)); err != nil {
+				return err
+			}
+		}
+
 		rows, err := db.NewInsert().
 			Model(s).
 			On("CONFLICT (endpoint_hash) DO UPDATE").
@@ -61,6 +80,9 @@ func (s *PushSubscription) Save(ctx context.Context, db bun.IDB) (err error) {
 			Set("client_id = EXCLUDED.client_id").
 			Set("recipient_id = EXCLUDED.recipient_id").
 			Set("expiration_time = EXCLUDED.expiration_time").
+			Set("locale = EXCLUDED.locale").
+			Set("push_service = EXCLUDED.push_service").
+			Set("updated_at = current_timestamp").
 			Exec(ctx)
 		if err != nil {
 			log.Printf("inserting subscription failed: %v", err)
@@ -89,6 +111,20 @@ func (s *PushSubscription) Save(ctx context.Context, db bun.IDB) (err error) {
 		}
 
 		s.Keys = keys
+
+		if s.Tags != nil {
+			hash := utils.Hash([]byte(*s.Endpoint))
+			hashes := [][]byte{hash[:]}
+
+			if err = deleteSubscriptionTags(ctx, db, hashes, nil); err != nil {
+				return err
+			}
+
+			if err = insertSubscriptionTags(ctx, db, hashes, s.Tags); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}
 
@@ -118,7 +154,7 @@ func (s PushSubscription) Validate() (err error) {
 func DeleteSubscriptionByEndpoint(ctx context.Context, db bun.IDB, endpoint string) (err error) {
 	if _, err = db.NewDelete().
 		Model((*PushSubscription)(nil)).
-		Where("endpoint_hash = ?", utils.HashedString(endpoint)).
+		Where("endpoint_hash IN (?)", bun.In(utils.Hashes([]byte(endpoint)))).
 		Exec(ctx); err != nil {
 		log.Printf("deleting subscription by endpoint failed: %v", err)
 		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete subscription", err.Error())
@@ -164,6 +200,30 @@ func DeleteSubscriptionsByClientIdAndRecipientId(ctx context.Context, db bun.IDB
 	return nil
 }
 
+// DeleteExpiredSubscriptions deletes all subscriptions past their expiration time, optionally limited to a client, returning the amount of deleted subscriptions.
+func DeleteExpiredSubscriptions(ctx context.Context, db bun.IDB, clientId string) (deleted int64, err error) {
+	return deleteSubscriptionsExpiredBefore(ctx, db, clientId, time.Now().UTC())
+}
+
+func deleteSubscriptionsExpiredBefore(ctx context.Context, db bun.IDB, clientId string, before time.Time) (deleted int64, err error) {
+	query := db.NewDelete().
+		Model((*PushSubscription)(nil)).
+		Where("expiration_time IS NOT NULL AND expiration_time <= ?", before)
+
+	if clientId != "" {
+		query = query.Where("client_id = ?", clientId)
+	}
+
+	res, err := query.Exec(ctx)
+	if err != nil {
+		log.Printf("deleting expired subscriptions failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete expired subscriptions", err.Error())
+		return 0, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return res.RowsAffected()
+}
+
 func GetSubscriptionByHash(ctx context.Context, db bun.IDB, hash string) (subscription *PushSubscription, err error) {
 	subscription = &PushSubscription{}
 
@@ -178,6 +238,7 @@ func GetSubscriptionByHash(ctx context.Context, db bun.IDB, hash string) (subscr
 		Model(subscription).
 		Where("endpoint_hash = ?", decoded).
 		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Where("quarantined_at IS NULL").
 		Relation("Keys").
 		Scan(ctx); err != nil {
 		log.Printf("fetching subscription by hash failed: %v", err)
@@ -195,6 +256,7 @@ func GetSubscriptionsByClientId(ctx context.Context, db bun.IDB, clientId string
 		Model(&subscriptions).
 		Where("client_id = ?", clientId).
 		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Where("quarantined_at IS NULL").
 		Relation("Keys").
 		Scan(ctx); err != nil {
 		log.Printf("fetching subscriptions by client ID failed: %v", err)
@@ -213,6 +275,7 @@ func GetSubscriptionsByClientIdAndRecipientId(ctx context.Context, db bun.IDB, c
 		Where("client_id = ?", clientId).
 		Where("recipient_id = ?", recipientId).
 		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Where("quarantined_at IS NULL").
 		Relation("Keys").
 		Scan(ctx); err != nil {
 		log.Printf("fetching subscriptions by client ID and recipient ID failed: %v", err)
@@ -223,11 +286,35 @@ func GetSubscriptionsByClientIdAndRecipientId(ctx context.Context, db bun.IDB, c
 	return subscriptions, nil
 }
 
+func GetSubscriptionsByClientIdAndRecipientIds(ctx context.Context, db bun.IDB, clientId string, recipientIds []string) (subscriptions []*PushSubscription, err error) {
+	subscriptions = make([]*PushSubscription, 0)
+
+	if len(recipientIds) == 0 {
+		return
+	}
+
+	if err = db.NewSelect().
+		Model(&subscriptions).
+		Where("client_id = ?", clientId).
+		Where("recipient_id IN (?)", bun.In(recipientIds)).
+		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Where("quarantined_at IS NULL").
+		Relation("Keys").
+		Scan(ctx); err != nil {
+		log.Printf("fetching subscriptions by client ID and recipient IDs failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscriptions", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return subscriptions, nil
+}
+
 func HasExistingSubscriptionsByClientId(ctx context.Context, db bun.IDB, clientId string) (exists bool, err error) {
 	exists, err = db.NewSelect().
 		Model((*PushSubscription)(nil)).
 		Where("client_id = ?", clientId).
 		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Where("quarantined_at IS NULL").
 		Exists(ctx)
 	if err != nil {
 		log.Printf("checking for existing subscriptions by client ID failed: %v", err)
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/uptrace/bun"
+)
+
+// ReplaceSubscription atomically replaces the subscription identified by any of oldHashes with sub, e.g. after a pushsubscriptionchange event.
+// The recipient, locale, tags and creation time of the old subscription are carried over, and the old subscription is deleted.
+// Only callers holding the auth secret of the old subscription may replace it.
+func ReplaceSubscription(ctx context.Context, db bun.IDB, clientId string, oldHashes [][]byte, oldAuthSecret []byte, sub *PushSubscription) (err error) {
+	errMsg := "Failed to replace subscription"
+
+	run := func(ctx context.Context, tx bun.Tx) error {
+		old := &PushSubscription{}
+
+		// expired subscriptions may be replaced as well, as browsers rotate subscriptions on expiry
+		if err := tx.NewSelect().
+			Model(old).
+			Where("ps.endpoint_hash IN (?)", bun.In(oldHashes)).
+			Where("ps.client_id = ?", clientId).
+			Relation("Keys").
+			For("UPDATE OF ps").
+			Scan(ctx); err != nil {
+			if err == sql.ErrNoRows {
+				payload := errors.NewErrorResponse(http.StatusNotFound, "Subscription not found")
+				return errors.NewResponseError(payload, http.StatusNotFound)
+			}
+
+			log.Printf("fetching subscription to replace failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		if old.Keys == nil || old.Keys.AuthSecret == nil || subtle.ConstantTimeCompare(*old.Keys.AuthSecret, oldAuthSecret) != 1 {
+			log.Printf("auth secret of %s does not match, refusing to replace it", old)
+			payload := errors.NewErrorResponse(http.StatusForbidden, "Forbidden", "The auth secret does not match the old subscription.")
+			return errors.NewResponseError(payload, http.StatusForbidden)
+		}
+
+		if err := loadSubscriptionTags(ctx, tx, []*PushSubscription{old}); err != nil {
+			return err
+		}
+
+		sub.ClientId = old.ClientId
+		sub.RecipientId = old.RecipientId
+		sub.Locale = old.Locale
+		sub.CreatedAt = old.CreatedAt
+		sub.Tags = old.Tags
+
+		if sub.Tags == nil {
+			sub.Tags = []string{}
+		}
+
+		// deleting first allows replacing a subscription with the same endpoint, but rotated keys
+		if _, err := tx.NewDelete().
+			Model((*PushSubscription)(nil)).
+			Where("endpoint_hash IN (?)", bun.In(oldHashes)).
+			Exec(ctx); err != nil {
+			log.Printf("deleting replaced subscription failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		return sub.Save(ctx, tx)
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, run)
+	}
+
+	return
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	"github.com/saschazar21/go-web-push-server/errors"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestReplaceSubscription(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	old := newTestSubscription(t, "recipient-0", "https://fcm.googleapis.com/fcm/send/old")
+	old.Locale = "de"
+	old.Tags = []string{"news"}
+	assert.NilError(t, old.Save(ctx, conn))
+
+	oldHash := utils.Hash([]byte("https://fcm.googleapis.com/fcm/send/old"))
+	oldAuthSecret := []byte(*old.Keys.AuthSecret)
+
+	t.Run("refuses a wrong auth secret", func(t *testing.T) {
+		sub := newTestSubscription(t, "", "https://fcm.googleapis.com/fcm/send/new")
+
+		err := ReplaceSubscription(ctx, conn, TEST_CLIENT_ID, [][]byte{oldHash[:]}, make([]byte, 16), sub)
+		assert.ErrorType(t, err, errors.ResponseError{})
+		assert.Equal(t, err.(errors.ResponseError).StatusCode, http.StatusForbidden)
+	})
+
+	t.Run("refuses an unknown subscription", func(t *testing.T) {
+		sub := newTestSubscription(t, "", "https://fcm.googleapis.com/fcm/send/new")
+		unknown := utils.Hash([]byte("https://fcm.googleapis.com/fcm/send/unknown"))
+
+		err := ReplaceSubscription(ctx, conn, TEST_CLIENT_ID, [][]byte{unknown[:]}, oldAuthSecret, sub)
+		assert.ErrorType(t, err, errors.ResponseError{})
+		assert.Equal(t, err.(errors.ResponseError).StatusCode, http.StatusNotFound)
+	})
+
+	t.Run("replaces the subscription", func(t *testing.T) {
+		sub := newTestSubscription(t, "", "https://fcm.googleapis.com/fcm/send/new")
+
+		assert.NilError(t, ReplaceSubscription(ctx, conn, TEST_CLIENT_ID, [][]byte{oldHash[:]}, oldAuthSecret, sub))
+
+		subscriptions, err := GetSubscriptionsByClientIdAndRecipientId(ctx, conn, TEST_CLIENT_ID, "recipient-0")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 1)
+		assert.Equal(t, string(*subscriptions[0].Endpoint), "https://fcm.googleapis.com/fcm/send/new")
+		assert.Equal(t, subscriptions[0].Locale, "de")
+		assert.DeepEqual(t, sub.Tags, []string{"news"})
+	})
+}
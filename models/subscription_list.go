@@ -0,0 +1,236 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/uptrace/bun"
+)
+
+const (
+	DEFAULT_SUBSCRIPTIONS_LIMIT = 50
+	MAX_SUBSCRIPTIONS_LIMIT     = 100
+)
+
+// SubscriptionCursor points to the last subscription of a page, ordered by creation time and endpoint hash.
+type SubscriptionCursor struct {
+	CreatedAt time.Time
+	Hash      []byte
+}
+
+// String encodes the cursor into an opaque, URL-safe string.
+func (c *SubscriptionCursor) String() string {
+	raw := fmt.Sprintf("%d.%s", c.CreatedAt.UnixMicro(), base64.RawURLEncoding.EncodeToString(c.Hash))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseSubscriptionCursor(s string) (cursor *SubscriptionCursor, err error) {
+	invalid := func() error {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid cursor")
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalid()
+	}
+
+	micros, hash, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, invalid()
+	}
+
+	createdAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return nil, invalid()
+	}
+
+	cursor = &SubscriptionCursor{CreatedAt: time.UnixMicro(createdAt).UTC()}
+
+	if cursor.Hash, err = base64.RawURLEncoding.DecodeString(hash); err != nil || len(cursor.Hash) == 0 {
+		return nil, invalid()
+	}
+
+	return cursor, nil
+}
+
+// SubscriptionFilter narrows down the subscriptions of a client, empty fields are ignored.
+type SubscriptionFilter struct {
+	RecipientId    string
+	PushService    string
+	ExpiringBefore *time.Time
+	CreatedAfter   *time.Time
+	// MinConsecutiveFailures only matches subscriptions whose latest deliveries failed at least as many times in a row.
+	MinConsecutiveFailures int
+	// Quarantined lists only quarantined subscriptions if true, or excludes them if false.
+	Quarantined *bool
+	Cursor      *SubscriptionCursor
+	Limit       int
+}
+
+func decodeSubscriptionHash(hash string) (decoded []byte, err error) {
+	if decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(hash, "=")); err != nil {
+		log.Printf("decoding subscription hash failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid subscription hash", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+// loadSubscriptionTags populates the tags of the given subscriptions.
+func loadSubscriptionTags(ctx context.Context, db bun.IDB, subscriptions []*PushSubscription) (err error) {
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	hashes := make([][]byte, 0, len(subscriptions))
+	byHash := make(map[string]*PushSubscription, len(subscriptions))
+
+	for _, sub := range subscriptions {
+		hash, err := decodeSubscriptionHash(sub.Hash.String())
+		if err != nil {
+			return err
+		}
+
+		hashes = append(hashes, hash)
+		byHash[string(hash)] = sub
+	}
+
+	tags := make([]*SubscriptionTag, 0)
+
+	if err = db.NewSelect().
+		Model(&tags).
+		Where("subscription_hash IN (?)", bun.In(hashes)).
+		Order("tag ASC").
+		Scan(ctx); err != nil {
+		log.Printf("fetching subscription tags failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscription tags", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	for _, tag := range tags {
+		if sub, ok := byHash[string(tag.SubscriptionHash)]; ok {
+			sub.Tags = append(sub.Tags, tag.Tag)
+		}
+	}
+
+	return
+}
+
+// GetSubscriptionByClientIdAndHash returns a single subscription of a client by its base64url-encoded endpoint hash, including its tags.
+func GetSubscriptionByClientIdAndHash(ctx context.Context, db bun.IDB, clientId, hash string) (subscription *PushSubscription, err error) {
+	decoded, err := decodeSubscriptionHash(hash)
+	if err != nil {
+		return
+	}
+
+	subscription = &PushSubscription{}
+
+	if err = db.NewSelect().
+		Model(subscription).
+		Where("endpoint_hash = ?", decoded).
+		Where("client_id = ?", clientId).
+		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			payload := errors.NewErrorResponse(http.StatusNotFound, "Subscription not found")
+			return nil, errors.NewResponseError(payload, http.StatusNotFound)
+		}
+
+		log.Printf("fetching subscription by client ID and hash failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscription", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	if err = loadSubscriptionTags(ctx, db, []*PushSubscription{subscription}); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// ListSubscriptions returns a page of the subscriptions of a client, including their tags,
+// along with the cursor of the next page, which is nil on the last page.
+func ListSubscriptions(ctx context.Context, db bun.IDB, clientId string, filter *SubscriptionFilter) (subscriptions []*PushSubscription, next *SubscriptionCursor, err error) {
+	subscriptions = make([]*PushSubscription, 0)
+
+	limit := filter.Limit
+
+	if limit <= 0 || limit > MAX_SUBSCRIPTIONS_LIMIT {
+		limit = DEFAULT_SUBSCRIPTIONS_LIMIT
+	}
+
+	query := db.NewSelect().
+		Model(&subscriptions).
+		Where("client_id = ?", clientId).
+		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Order("created_at ASC", "endpoint_hash ASC").
+		Limit(limit + 1)
+
+	if filter.RecipientId != "" {
+		query = query.Where("recipient_id = ?", filter.RecipientId)
+	}
+
+	if filter.PushService != "" {
+		query = query.Where("push_service = ?", filter.PushService)
+	}
+
+	if filter.ExpiringBefore != nil {
+		query = query.Where("expiration_time IS NOT NULL AND expiration_time < ?", filter.ExpiringBefore.UTC())
+	}
+
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at > ?", filter.CreatedAfter.UTC())
+	}
+
+	if filter.Quarantined != nil {
+		if *filter.Quarantined {
+			query = query.Where("quarantined_at IS NOT NULL")
+		} else {
+			query = query.Where("quarantined_at IS NULL")
+		}
+	}
+
+	if filter.MinConsecutiveFailures > 0 {
+		query = query.Where("consecutive_failures >= ?", filter.MinConsecutiveFailures)
+	}
+
+	if filter.Cursor != nil {
+		query = query.Where("(created_at, endpoint_hash) > (?, ?)", filter.Cursor.CreatedAt, filter.Cursor.Hash)
+	}
+
+	if err = query.Scan(ctx); err != nil {
+		log.Printf("listing subscriptions failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscriptions", err.Error())
+		return nil, nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	// the additional row only signals the existence of a next page
+	if len(subscriptions) > limit {
+		subscriptions = subscriptions[:limit]
+		last := subscriptions[limit-1]
+
+		hash, err := decodeSubscriptionHash(last.Hash.String())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		next = &SubscriptionCursor{CreatedAt: last.CreatedAt, Hash: hash}
+	}
+
+	if err = loadSubscriptionTags(ctx, db, subscriptions); err != nil {
+		return nil, nil, err
+	}
+
+	return
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func newTestSubscription(t *testing.T, recipientId, endpoint string) *PushSubscription {
+	privateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+
+	p256dh := privateKey.PublicKey().Bytes()
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+
+	return &PushSubscription{
+		ClientId:    TEST_CLIENT_ID,
+		RecipientId: recipientId,
+		Endpoint:    (*utils.EncryptedString)(&endpoint),
+		Keys: &SubscriptionKeys{
+			P256DH:     (*utils.EncryptedBytes)(&p256dh),
+			AuthSecret: (*utils.EncryptedBytes)(&authSecret),
+		},
+	}
+}
+
+func TestSubscriptionCursor(t *testing.T) {
+	cursor := &SubscriptionCursor{CreatedAt: time.UnixMicro(1700000000123456).UTC(), Hash: []byte{0xff, 0x00, 0x01}}
+
+	parsed, err := ParseSubscriptionCursor(cursor.String())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, parsed, cursor)
+
+	for _, invalid := range []string{"", "!", "MTIz", "YWJjLmRlZg"} {
+		_, err := ParseSubscriptionCursor(invalid)
+		assert.ErrorContains(t, err, "400", "cursor = %q", invalid)
+	}
+}
+
+func TestListSubscriptions(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	for i := 0; i < 5; i++ {
+		sub := newTestSubscription(t, fmt.Sprintf("recipient-%d", i%2), fmt.Sprintf("https://fcm.googleapis.com/fcm/send/%d", i))
+
+		if i == 0 {
+			sub.Tags = []string{"beta", "alpha"}
+		}
+
+		assert.NilError(t, sub.Save(ctx, conn))
+	}
+
+	autopush := newTestSubscription(t, "recipient-0", "https://updates.push.services.mozilla.com/wpush/v2/0")
+	assert.NilError(t, autopush.Save(ctx, conn))
+
+	t.Run("paginates all subscriptions", func(t *testing.T) {
+		seen := map[string]bool{}
+		filter := &SubscriptionFilter{Limit: 4}
+
+		page, next, err := ListSubscriptions(ctx, conn, TEST_CLIENT_ID, filter)
+		assert.NilError(t, err)
+		assert.Equal(t, len(page), 4)
+		assert.Assert(t, next != nil)
+
+		for _, sub := range page {
+			seen[sub.Hash.String()] = true
+		}
+
+		filter.Cursor = next
+
+		page, next, err = ListSubscriptions(ctx, conn, TEST_CLIENT_ID, filter)
+		assert.NilError(t, err)
+		assert.Equal(t, len(page), 2)
+		assert.Assert(t, next == nil)
+
+		for _, sub := range page {
+			seen[sub.Hash.String()] = true
+		}
+
+		assert.Equal(t, len(seen), 6)
+	})
+
+	t.Run("filters by recipient and push service", func(t *testing.T) {
+		page, _, err := ListSubscriptions(ctx, conn, TEST_CLIENT_ID, &SubscriptionFilter{RecipientId: "recipient-0"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page), 4)
+
+		page, _, err = ListSubscriptions(ctx, conn, TEST_CLIENT_ID, &SubscriptionFilter{RecipientId: "recipient-0", PushService: "autopush"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page), 1)
+	})
+
+	t.Run("filters by creation time", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+
+		page, _, err := ListSubscriptions(ctx, conn, TEST_CLIENT_ID, &SubscriptionFilter{CreatedAfter: &future})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page), 0)
+	})
+
+	t.Run("isolates clients", func(t *testing.T) {
+		page, _, err := ListSubscriptions(ctx, conn, "other client", &SubscriptionFilter{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(page), 0)
+	})
+
+	t.Run("looks up a single subscription including its tags", func(t *testing.T) {
+		page, _, err := ListSubscriptions(ctx, conn, TEST_CLIENT_ID, &SubscriptionFilter{})
+		assert.NilError(t, err)
+
+		var tagged *PushSubscription
+
+		for _, sub := range page {
+			if len(sub.Tags) > 0 {
+				tagged = sub
+			}
+		}
+
+		assert.Assert(t, tagged != nil)
+
+		sub, err := GetSubscriptionByClientIdAndHash(ctx, conn, TEST_CLIENT_ID, tagged.Hash.String())
+		assert.NilError(t, err)
+		assert.Equal(t, sub.RecipientId, tagged.RecipientId)
+		assert.DeepEqual(t, sub.Tags, []string{"alpha", "beta"})
+
+		_, err = GetSubscriptionByClientIdAndHash(ctx, conn, "other client", tagged.Hash.String())
+		assert.ErrorContains(t, err, "404")
+	})
+}
@@ -219,6 +219,26 @@ func TestSubscription(t *testing.T) {
 				if count != 0 {
 					t.Errorf("expected 0 subscriptions, got %d", count)
 				}
+
+				if err := tc.subscription.Save(ctx, conn); err != nil {
+					t.Errorf("Save() error = %v, wantErr %v", err, false)
+				}
+
+				if deleted, err := DeleteExpiredSubscriptions(ctx, conn, ""); err != nil || deleted != 0 {
+					t.Errorf("DeleteExpiredSubscriptions() deleted = %d, error = %v, want 0 deleted", deleted, err)
+				}
+
+				if _, err := conn.NewUpdate().
+					Model((*PushSubscription)(nil)).
+					Set("expiration_time = ?", ONE_HOUR_AGO).
+					Where("client_id = ?", TEST_CLIENT_ID).
+					Exec(ctx); err != nil {
+					t.Fatalf("failed to expire subscription: %v", err)
+				}
+
+				if deleted, err := DeleteExpiredSubscriptions(ctx, conn, TEST_CLIENT_ID); err != nil || deleted != 1 {
+					t.Errorf("DeleteExpiredSubscriptions() deleted = %d, error = %v, want 1 deleted", deleted, err)
+				}
 			}
 		})
 	}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const DEFAULT_SWEEP_GRACE_PERIOD = 24 * time.Hour
+
+// SweepResult holds the amount of rows deleted by a sweep.
+type SweepResult struct {
+	ExpiredSubscriptions   int64 `json:"expiredSubscriptions"`
+	OrphanedKeys           int64 `json:"orphanedKeys"`
+	ExpiredIdempotencyKeys int64 `json:"expiredIdempotencyKeys"`
+}
+
+// GetSweepGracePeriod returns the duration expired subscriptions are retained before being swept.
+func GetSweepGracePeriod() time.Duration {
+	env := strings.TrimSpace(os.Getenv(utils.SWEEP_GRACE_PERIOD_ENV))
+
+	if env == "" {
+		return DEFAULT_SWEEP_GRACE_PERIOD
+	}
+
+	grace, err := time.ParseDuration(env)
+
+	if err != nil || grace < 0 {
+		log.Printf("%s env must be a non-negative duration, e.g. 72h, falling back to default: %s\n", utils.SWEEP_GRACE_PERIOD_ENV, DEFAULT_SWEEP_GRACE_PERIOD)
+		grace = DEFAULT_SWEEP_GRACE_PERIOD
+	}
+
+	return grace
+}
+
+// Sweep deletes all subscriptions, which expired longer than the grace period ago, all keys no longer belonging to a subscription,
+// and all idempotency keys past their idempotency window.
+func Sweep(ctx context.Context, db bun.IDB, grace time.Duration) (result *SweepResult, err error) {
+	result = &SweepResult{}
+
+	run := func(ctx context.Context, tx bun.Tx) (err error) {
+		if result.ExpiredSubscriptions, err = deleteSubscriptionsExpiredBefore(ctx, tx, "", time.Now().UTC().Add(-grace)); err != nil {
+			return
+		}
+
+		// keys cascade with their subscription, this only catches rows left behind by a schema without the foreign key
+		res, err := tx.NewDelete().
+			Model((*SubscriptionKeys)(nil)).
+			Where("NOT EXISTS (?)", tx.NewSelect().
+				Model((*PushSubscription)(nil)).
+				ColumnExpr("1").
+				Where("ps.endpoint_hash = pk.subscription_hash")).
+			Exec(ctx)
+		if err != nil {
+			log.Printf("deleting orphaned subscription keys failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete orphaned subscription keys", err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		if result.OrphanedKeys, err = res.RowsAffected(); err != nil {
+			return
+		}
+
+		// expired idempotency keys are otherwise only replaced, when the same client reuses the same key
+		if res, err = tx.NewDelete().
+			Model((*IdempotencyKey)(nil)).
+			Where("expires_at <= ?", time.Now().UTC()).
+			Exec(ctx); err != nil {
+			log.Printf("deleting expired idempotency keys failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete expired idempotency keys", err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		result.ExpiredIdempotencyKeys, err = res.RowsAffected()
+
+		return
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, run)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("sweep deleted %d expired subscriptions, %d orphaned keys and %d expired idempotency keys\n", result.ExpiredSubscriptions, result.OrphanedKeys, result.ExpiredIdempotencyKeys)
+
+	return
+}
+
+// RunSweeper sweeps once immediately and then in the given interval, until the context is done.
+// Failed sweeps are logged and retried in the next interval.
+func RunSweeper(ctx context.Context, db bun.IDB, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := Sweep(ctx, db, grace); err != nil {
+			log.Printf("sweep failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
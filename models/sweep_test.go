@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestGetSweepGracePeriod(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want time.Duration
+	}{
+		{"should fall back to default on unset env", "", DEFAULT_SWEEP_GRACE_PERIOD},
+		{"should parse duration", "72h", 72 * time.Hour},
+		{"should allow zero grace period", "0s", 0},
+		{"should fall back to default on negative duration", "-1h", DEFAULT_SWEEP_GRACE_PERIOD},
+		{"should fall back to default on invalid duration", "three days", DEFAULT_SWEEP_GRACE_PERIOD},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(utils.SWEEP_GRACE_PERIOD_ENV, tt.env)
+
+			assert.Equal(t, GetSweepGracePeriod(), tt.want)
+		})
+	}
+}
+
+func TestSweep(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NilError(t, err)
+
+	defer conn.Close()
+
+	expirations := map[string]time.Duration{
+		"https://fcm.googleapis.com/fcm/send/long-expired":     -3 * time.Hour,
+		"https://fcm.googleapis.com/fcm/send/recently-expired": -30 * time.Minute,
+		"https://fcm.googleapis.com/fcm/send/active":           time.Hour,
+	}
+
+	for endpoint, offset := range expirations {
+		assert.NilError(t, newTestSubscription(t, "recipient-0", endpoint).Save(ctx, conn))
+
+		hash := utils.Hash([]byte(endpoint))
+
+		_, err := conn.NewUpdate().
+			Model((*PushSubscription)(nil)).
+			Set("expiration_time = ?", time.Now().Add(offset).UTC()).
+			Where("endpoint_hash = ?", hash[:]).
+			Exec(ctx)
+		assert.NilError(t, err)
+	}
+
+	for id, window := range map[string]time.Duration{"expired": -time.Minute, "active": time.Hour} {
+		key := NewIdempotencyKey(TEST_CLIENT_ID, id, make([]byte, 32))
+		key.ExpiresAt = time.Now().Add(window).UTC()
+
+		existing, err := key.Reserve(ctx, conn)
+		assert.NilError(t, err)
+		assert.Assert(t, existing == nil)
+	}
+
+	result, err := Sweep(ctx, conn, time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, result.ExpiredSubscriptions, int64(1))
+	assert.Equal(t, result.OrphanedKeys, int64(0))
+	assert.Equal(t, result.ExpiredIdempotencyKeys, int64(1))
+
+	result, err = Sweep(ctx, conn, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, result.ExpiredSubscriptions, int64(1))
+
+	remaining, err := conn.NewSelect().Model((*PushSubscription)(nil)).Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, remaining, 1)
+
+	keys, err := conn.NewSelect().Model((*SubscriptionKeys)(nil)).Count(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, keys, 1)
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+type SubscriptionTag struct {
+	bun.BaseModel `bun:"table:webpush_subscription_tags,alias:pst"`
+
+	SubscriptionHash []byte `json:"-" bun:"subscription_hash,type:bytea,pk"`
+	Tag              string `json:"tag" validate:"tag" bun:"tag,pk"`
+}
+
+func newSubscriptionTags(hashes [][]byte, tags []string) (subscriptionTags []*SubscriptionTag) {
+	subscriptionTags = make([]*SubscriptionTag, 0, len(hashes)*len(tags))
+
+	for _, hash := range hashes {
+		for _, tag := range tags {
+			subscriptionTags = append(subscriptionTags, &SubscriptionTag{
+				SubscriptionHash: hash,
+				Tag:              tag,
+			})
+		}
+	}
+
+	return
+}
+
+func insertSubscriptionTags(ctx context.Context, db bun.IDB, hashes [][]byte, tags []string) (err error) {
+	subscriptionTags := newSubscriptionTags(hashes, tags)
+
+	if len(subscriptionTags) == 0 {
+		return
+	}
+
+	if _, err = db.NewInsert().
+		Model(&subscriptionTags).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx); err != nil {
+		log.Printf("inserting subscription tags failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to store subscription tags", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func deleteSubscriptionTags(ctx context.Context, db bun.IDB, hashes [][]byte, tags []string) (err error) {
+	if len(hashes) == 0 {
+		return
+	}
+
+	query := db.NewDelete().
+		Model((*SubscriptionTag)(nil)).
+		Where("subscription_hash IN (?)", bun.In(hashes))
+
+	// a nil slice deletes all tags of the given subscriptions
+	if tags != nil {
+		query = query.Where("tag IN (?)", bun.In(tags))
+	}
+
+	if _, err = query.Exec(ctx); err != nil {
+		log.Printf("deleting subscription tags failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete subscription tags", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func validateTags(tags []string) (err error) {
+	s := struct {
+		Tags []string `validate:"max=32,dive,tag"`
+	}{tags}
+
+	if err = utils.CustomValidateStruct(s); err != nil {
+		log.Printf("invalid subscription tags: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid subscription tags", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func getSubscriptionHashesByClientIdAndRecipientId(ctx context.Context, db bun.IDB, clientId, recipientId string) (hashes [][]byte, err error) {
+	hashes = make([][]byte, 0)
+
+	if err = db.NewSelect().
+		Model((*PushSubscription)(nil)).
+		Column("endpoint_hash").
+		Where("client_id = ?", clientId).
+		Where("recipient_id = ?", recipientId).
+		Scan(ctx, &hashes); err != nil {
+		log.Printf("fetching subscription hashes failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscriptions", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	if len(hashes) == 0 {
+		payload := errors.NewErrorResponse(http.StatusNotFound, "no subscriptions found")
+		return nil, errors.NewResponseError(payload, http.StatusNotFound)
+	}
+
+	return
+}
+
+func updateTagsByClientIdAndRecipientId(ctx context.Context, db bun.IDB, clientId, recipientId string, run func(ctx context.Context, db bun.Tx, hashes [][]byte) error) (err error) {
+	wrapped := func(ctx context.Context, db bun.Tx) error {
+		hashes, err := getSubscriptionHashesByClientIdAndRecipientId(ctx, db, clientId, recipientId)
+		if err != nil {
+			return err
+		}
+
+		return run(ctx, db, hashes)
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = wrapped(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, wrapped)
+	}
+
+	return
+}
+
+func SetTagsByClientIdAndRecipientId(ctx context.Context, db bun.IDB, clientId, recipientId string, tags []string) (err error) {
+	if err = validateTags(tags); err != nil {
+		return
+	}
+
+	return updateTagsByClientIdAndRecipientId(ctx, db, clientId, recipientId, func(ctx context.Context, db bun.Tx, hashes [][]byte) error {
+		if err := deleteSubscriptionTags(ctx, db, hashes, nil); err != nil {
+			return err
+		}
+
+		return insertSubscriptionTags(ctx, db, hashes, tags)
+	})
+}
+
+func UpdateTagsByClientIdAndRecipientId(ctx context.Context, db bun.IDB, clientId, recipientId string, add, remove []string) (err error) {
+	if err = validateTags(add); err != nil {
+		return
+	}
+
+	if err = validateTags(remove); err != nil {
+		return
+	}
+
+	return updateTagsByClientIdAndRecipientId(ctx, db, clientId, recipientId, func(ctx context.Context, db bun.Tx, hashes [][]byte) error {
+		if len(remove) > 0 {
+			if err := deleteSubscriptionTags(ctx, db, hashes, remove); err != nil {
+				return err
+			}
+		}
+
+		return insertSubscriptionTags(ctx, db, hashes, add)
+	})
+}
+
+func GetTagsByClientIdAndRecipientId(ctx context.Context, db bun.IDB, clientId, recipientId string) (tags []string, err error) {
+	tags = make([]string, 0)
+
+	if err = db.NewSelect().
+		Model((*SubscriptionTag)(nil)).
+		Distinct().
+		Column("pst.tag").
+		Join("JOIN webpush_subscriptions AS ps ON ps.endpoint_hash = pst.subscription_hash").
+		Where("ps.client_id = ?", clientId).
+		Where("ps.recipient_id = ?", recipientId).
+		Order("pst.tag ASC").
+		Scan(ctx, &tags); err != nil {
+		log.Printf("fetching subscription tags failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscription tags", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+// GetSubscriptionsByTags returns all subscriptions of a client matching the tag expression,
+// optionally narrowed down to a single recipient, if recipientId is not empty.
+func GetSubscriptionsByTags(ctx context.Context, db bun.IDB, clientId, recipientId string, expr TagExpression) (subscriptions []*PushSubscription, err error) {
+	subscriptions = make([]*PushSubscription, 0)
+
+	where, args := expr.SQL()
+
+	query := db.NewSelect().
+		Model(&subscriptions).
+		Where("client_id = ?", clientId).
+		Where("expiration_time IS NULL OR expiration_time > ?", time.Now().UTC()).
+		Where("quarantined_at IS NULL").
+		Where(where, args...).
+		Relation("Keys")
+
+	if recipientId != "" {
+		query = query.Where("recipient_id = ?", recipientId)
+	}
+
+	if err = query.Scan(ctx); err != nil {
+		log.Printf("fetching subscriptions by tags failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscriptions", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return subscriptions, nil
+}
+
+func (t SubscriptionTag) String() string {
+	return fmt.Sprintf("[Subscription Tag] %s", t.Tag)
+}
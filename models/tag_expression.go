@@ -0,0 +1,233 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const MAX_TAG_EXPRESSION_TOKENS = 64
+
+const (
+	TAG_OPERATOR_AND = "AND"
+	TAG_OPERATOR_OR  = "OR"
+	TAG_OPERATOR_NOT = "NOT"
+)
+
+// TagExpression is a boolean expression over subscription tags, e.g. "beta AND (plan:pro OR plan:team) AND NOT churned".
+type TagExpression interface {
+	String() string
+	SQL() (string, []any)
+	// Matches evaluates the expression against the tags of a single subscription, e.g. for stores without SQL.
+	Matches(tags []string) bool
+}
+
+type tagLeaf string
+
+func (t tagLeaf) String() string {
+	return string(t)
+}
+
+func (t tagLeaf) SQL() (string, []any) {
+	return "EXISTS (SELECT 1 FROM webpush_subscription_tags AS pst WHERE pst.subscription_hash = ps.endpoint_hash AND pst.tag = ?)", []any{string(t)}
+}
+
+func (t tagLeaf) Matches(tags []string) bool {
+	return slices.Contains(tags, string(t))
+}
+
+type tagNot struct {
+	expr TagExpression
+}
+
+func (t *tagNot) String() string {
+	return fmt.Sprintf("%s %s", TAG_OPERATOR_NOT, t.expr)
+}
+
+func (t *tagNot) SQL() (string, []any) {
+	query, args := t.expr.SQL()
+
+	return fmt.Sprintf("NOT (%s)", query), args
+}
+
+func (t *tagNot) Matches(tags []string) bool {
+	return !t.expr.Matches(tags)
+}
+
+type tagBinary struct {
+	operator    string
+	left, right TagExpression
+}
+
+func (t *tagBinary) String() string {
+	return fmt.Sprintf("(%s %s %s)", t.left, t.operator, t.right)
+}
+
+func (t *tagBinary) SQL() (string, []any) {
+	left, leftArgs := t.left.SQL()
+	right, rightArgs := t.right.SQL()
+
+	return fmt.Sprintf("(%s) %s (%s)", left, t.operator, right), append(leftArgs, rightArgs...)
+}
+
+func (t *tagBinary) Matches(tags []string) bool {
+	if t.operator == TAG_OPERATOR_AND {
+		return t.left.Matches(tags) && t.right.Matches(tags)
+	}
+
+	return t.left.Matches(tags) || t.right.Matches(tags)
+}
+
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func tokenizeTagExpression(s string) (tokens []string) {
+	s = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s)
+
+	return strings.Fields(s)
+}
+
+func (p *tagParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *tagParser) next() string {
+	token := p.peek()
+	p.pos++
+
+	return token
+}
+
+func (p *tagParser) parseOr() (expr TagExpression, err error) {
+	if expr, err = p.parseAnd(); err != nil {
+		return
+	}
+
+	for strings.EqualFold(p.peek(), TAG_OPERATOR_OR) {
+		p.next()
+
+		var right TagExpression
+
+		if right, err = p.parseAnd(); err != nil {
+			return
+		}
+
+		expr = &tagBinary{TAG_OPERATOR_OR, expr, right}
+	}
+
+	return
+}
+
+func (p *tagParser) parseAnd() (expr TagExpression, err error) {
+	if expr, err = p.parseNot(); err != nil {
+		return
+	}
+
+	for strings.EqualFold(p.peek(), TAG_OPERATOR_AND) {
+		p.next()
+
+		var right TagExpression
+
+		if right, err = p.parseNot(); err != nil {
+			return
+		}
+
+		expr = &tagBinary{TAG_OPERATOR_AND, expr, right}
+	}
+
+	return
+}
+
+func (p *tagParser) parseNot() (expr TagExpression, err error) {
+	if strings.EqualFold(p.peek(), TAG_OPERATOR_NOT) {
+		p.next()
+
+		if expr, err = p.parseNot(); err != nil {
+			return
+		}
+
+		return &tagNot{expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *tagParser) parsePrimary() (expr TagExpression, err error) {
+	token := p.next()
+
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of tag expression")
+	case token == "(":
+		if expr, err = p.parseOr(); err != nil {
+			return
+		}
+
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in tag expression")
+		}
+
+		return
+	case token == ")", isTagOperator(token):
+		return nil, fmt.Errorf("unexpected token %q in tag expression", token)
+	case !utils.IsValidTag(token):
+		return nil, fmt.Errorf("invalid tag %q in tag expression", token)
+	}
+
+	return tagLeaf(token), nil
+}
+
+func isTagOperator(token string) bool {
+	for _, operator := range []string{TAG_OPERATOR_AND, TAG_OPERATOR_OR, TAG_OPERATOR_NOT} {
+		if strings.EqualFold(token, operator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseTagExpression(s string) (expr TagExpression, err error) {
+	tokens := tokenizeTagExpression(s)
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty tag expression")
+	}
+
+	if len(tokens) > MAX_TAG_EXPRESSION_TOKENS {
+		return nil, fmt.Errorf("tag expression must not exceed %d tokens", MAX_TAG_EXPRESSION_TOKENS)
+	}
+
+	p := &tagParser{tokens: tokens}
+
+	if expr, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q in tag expression", p.peek())
+	}
+
+	return
+}
+
+func ParseTagExpression(s string) (expr TagExpression, err error) {
+	if expr, err = parseTagExpression(s); err != nil {
+		log.Printf("parsing tag expression failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid tag expression", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
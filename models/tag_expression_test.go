@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTagExpression(t *testing.T) {
+	type test struct {
+		name       string
+		expression string
+		wantErr    bool
+		want       string
+		wantArgs   []any
+	}
+
+	tests := []test{
+		{"single tag", "beta", false, "beta", []any{"beta"}},
+		{"AND binds tighter than OR", "beta OR plan:pro AND team:42", false, "(beta OR (plan:pro AND team:42))", []any{"beta", "plan:pro", "team:42"}},
+		{"parentheses override precedence", "(beta OR plan:pro) AND NOT churned", false, "((beta OR plan:pro) AND NOT churned)", []any{"beta", "plan:pro", "churned"}},
+		{"operators are case-insensitive", "beta and not alpha", false, "(beta AND NOT alpha)", []any{"beta", "alpha"}},
+		{"fails on empty expression", "  ", true, "", nil},
+		{"fails on dangling operator", "beta AND", true, "", nil},
+		{"fails on missing parenthesis", "(beta OR alpha", true, "", nil},
+		{"fails on superfluous parenthesis", "beta)", true, "", nil},
+		{"fails on missing operator", "beta alpha", true, "", nil},
+		{"fails on invalid tag", "beta AND 'alpha'", true, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseTagExpression(tt.expression)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTagExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			_, args := expr.SQL()
+
+			assert.Equal(t, tt.want, expr.String())
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestTagExpressionMatches(t *testing.T) {
+	type test struct {
+		expression string
+		tags       []string
+		want       bool
+	}
+
+	tests := []test{
+		{"beta", []string{"beta"}, true},
+		{"beta", nil, false},
+		{"beta OR plan:pro AND team:42", []string{"plan:pro"}, false},
+		{"beta OR plan:pro AND team:42", []string{"plan:pro", "team:42"}, true},
+		{"(beta OR plan:pro) AND NOT churned", []string{"beta"}, true},
+		{"(beta OR plan:pro) AND NOT churned", []string{"beta", "churned"}, false},
+		{"NOT churned", nil, true},
+	}
+
+	for _, tt := range tests {
+		expr, err := ParseTagExpression(tt.expression)
+		assert.NoError(t, err)
+
+		assert.Equal(t, tt.want, expr.Matches(tt.tags), "%s matching %v", tt.expression, tt.tags)
+	}
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+type RenderedNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Data  any    `json:"data,omitempty"`
+}
+
+type NotificationTemplate struct {
+	bun.BaseModel `bun:"table:webpush_templates,alias:nt"`
+
+	ClientId   string         `json:"-" validate:"required" bun:"client_id,pk"`
+	TemplateId string         `json:"id" validate:"required,max=255" bun:"template_id,pk"`
+	Version    int            `json:"version" bun:"version,pk"`
+	Title      string         `json:"title" validate:"required" bun:"title,notnull"`
+	Body       string         `json:"body,omitempty" bun:"body,nullzero"`
+	Icon       string         `json:"icon,omitempty" bun:"icon,nullzero"`
+	Tag        string         `json:"tag,omitempty" bun:"tag,nullzero"`
+	URL        string         `json:"url,omitempty" bun:"url,nullzero"`
+	Data       map[string]any `json:"data,omitempty" bun:"data,type:jsonb"`
+	CreatedAt  time.Time      `json:"createdAt" bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+func renderString(name, text string, variables map[string]any) (s string, err error) {
+	var tpl *template.Template
+
+	if tpl, err = template.New(name).Option("missingkey=error").Parse(text); err != nil {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err = tpl.Execute(buf, variables); err != nil {
+		return
+	}
+
+	return buf.String(), nil
+}
+
+func renderValue(name string, value any, variables map[string]any) (rendered any, err error) {
+	switch v := value.(type) {
+	case string:
+		return renderString(name, v, variables)
+	case map[string]any:
+		m := make(map[string]any, len(v))
+
+		for key, val := range v {
+			if m[key], err = renderValue(fmt.Sprintf("%s.%s", name, key), val, variables); err != nil {
+				return
+			}
+		}
+
+		return m, nil
+	case []any:
+		s := make([]any, len(v))
+
+		for i, val := range v {
+			if s[i], err = renderValue(fmt.Sprintf("%s[%d]", name, i), val, variables); err != nil {
+				return
+			}
+		}
+
+		return s, nil
+	default:
+		return v, nil
+	}
+}
+
+// parseValue parses every string within the value, the same way renderValue renders them.
+func parseValue(name string, value any) (err error) {
+	switch v := value.(type) {
+	case string:
+		_, err = template.New(name).Parse(v)
+	case map[string]any:
+		for key, val := range v {
+			if err = parseValue(fmt.Sprintf("%s.%s", name, key), val); err != nil {
+				return
+			}
+		}
+	case []any:
+		for i, val := range v {
+			if err = parseValue(fmt.Sprintf("%s[%d]", name, i), val); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (t *NotificationTemplate) Render(variables map[string]any) (buf []byte, err error) {
+	if variables == nil {
+		variables = map[string]any{}
+	}
+
+	rendered := &RenderedNotification{}
+
+	fields := []struct {
+		name string
+		text string
+		dst  *string
+	}{
+		{"title", t.Title, &rendered.Title},
+		{"body", t.Body, &rendered.Body},
+		{"icon", t.Icon, &rendered.Icon},
+		{"tag", t.Tag, &rendered.Tag},
+		{"url", t.URL, &rendered.URL},
+	}
+
+	for _, f := range fields {
+		if *f.dst, err = renderString(f.name, f.text, variables); err != nil {
+			log.Printf("rendering %s failed: %v", t, err)
+			payload := errors.NewErrorResponse(http.StatusBadRequest, "Failed to render notification template", err.Error())
+			return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+		}
+	}
+
+	if t.Data != nil {
+		if rendered.Data, err = renderValue("data", t.Data, variables); err != nil {
+			log.Printf("rendering %s failed: %v", t, err)
+			payload := errors.NewErrorResponse(http.StatusBadRequest, "Failed to render notification template", err.Error())
+			return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+		}
+	}
+
+	if buf, err = json.Marshal(rendered); err != nil {
+		log.Printf("encoding rendered %s failed: %v", t, err)
+		return nil, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func (t *NotificationTemplate) Save(ctx context.Context, db bun.IDB) (err error) {
+	if err = t.Validate(); err != nil {
+		return
+	}
+
+	errMsg := "Failed to store notification template in database"
+
+	run := func(ctx context.Context, db bun.Tx) error {
+		var version sql.NullInt64
+
+		// concurrent saves would otherwise read the same latest version, FOR UPDATE can't lock the first version, which doesn't exist yet
+		if _, err := db.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", t.ClientId+"\x00"+t.TemplateId); err != nil {
+			log.Printf("locking notification template failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		if err := db.NewSelect().
+			Model((*NotificationTemplate)(nil)).
+			ColumnExpr("MAX(version)").
+			Where("client_id = ?", t.ClientId).
+			Where("template_id = ?", t.TemplateId).
+			Scan(ctx, &version); err != nil {
+			log.Printf("fetching latest template version failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		t.Version = int(version.Int64) + 1
+
+		if _, err := db.NewInsert().
+			Model(t).
+			Returning("created_at").
+			Exec(ctx); err != nil {
+			log.Printf("inserting notification template failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusInternalServerError, errMsg, err.Error())
+			return errors.NewResponseError(payload, http.StatusInternalServerError)
+		}
+
+		return nil
+	}
+
+	if tx, ok := db.(bun.Tx); ok {
+		err = run(ctx, tx)
+	} else {
+		err = db.RunInTx(ctx, nil, run)
+	}
+
+	return
+}
+
+func (t NotificationTemplate) String() string {
+	return fmt.Sprintf("[Notification Template] %s v%d (Client: %s)", t.TemplateId, t.Version, t.ClientId)
+}
+
+func (t NotificationTemplate) Validate() (err error) {
+	if err = utils.CustomValidateStruct(t); err != nil {
+		log.Printf("invalid notification template: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid notification template", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	for name, text := range map[string]string{"title": t.Title, "body": t.Body, "icon": t.Icon, "tag": t.Tag, "url": t.URL} {
+		if _, err = template.New(name).Parse(text); err != nil {
+			log.Printf("invalid notification template: %v", err)
+			payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid notification template", err.Error())
+			return errors.NewResponseError(payload, http.StatusBadRequest)
+		}
+	}
+
+	if t.Data != nil {
+		if err = parseValue("data", t.Data); err != nil {
+			log.Printf("invalid notification template: %v", err)
+			payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid notification template", err.Error())
+			return errors.NewResponseError(payload, http.StatusBadRequest)
+		}
+	}
+
+	return
+}
+
+func DeleteTemplate(ctx context.Context, db bun.IDB, clientId, templateId string) (err error) {
+	if _, err = db.NewDelete().
+		Model((*NotificationTemplate)(nil)).
+		Where("client_id = ?", clientId).
+		Where("template_id = ?", templateId).
+		Exec(ctx); err != nil {
+		log.Printf("deleting notification template failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to delete notification template", err.Error())
+		return errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// GetTemplate returns the given version of a notification template, or the latest version, if version is 0.
+func GetTemplate(ctx context.Context, db bun.IDB, clientId, templateId string, version int) (tpl *NotificationTemplate, err error) {
+	tpl = &NotificationTemplate{}
+
+	query := db.NewSelect().
+		Model(tpl).
+		Where("client_id = ?", clientId).
+		Where("template_id = ?", templateId)
+
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Order("version DESC").Limit(1)
+	}
+
+	if err = query.Scan(ctx); err != nil {
+		if err == sql.ErrNoRows {
+			payload := errors.NewErrorResponse(http.StatusNotFound, "Notification template not found")
+			return nil, errors.NewResponseError(payload, http.StatusNotFound)
+		}
+
+		log.Printf("fetching notification template failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch notification template", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return tpl, nil
+}
+
+func GetTemplateVersions(ctx context.Context, db bun.IDB, clientId, templateId string) (templates []*NotificationTemplate, err error) {
+	templates = make([]*NotificationTemplate, 0)
+
+	if err = db.NewSelect().
+		Model(&templates).
+		Where("client_id = ?", clientId).
+		Where("template_id = ?", templateId).
+		Order("version DESC").
+		Scan(ctx); err != nil {
+		log.Printf("fetching notification template versions failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch notification templates", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateRender(t *testing.T) {
+	tpl := &NotificationTemplate{
+		ClientId:   TEST_CLIENT_ID,
+		TemplateId: "new-messages",
+		Version:    1,
+		Title:      "Hello {{.name}}",
+		Body:       "You have {{.count}} new messages",
+		URL:        "https://example.com/inbox/{{.inbox}}",
+		Data: map[string]any{
+			"inbox": "{{.inbox}}",
+			"tags":  []any{"{{.name}}", float64(42)},
+		},
+	}
+
+	type test struct {
+		name      string
+		variables map[string]any
+		wantErr   bool
+		want      *RenderedNotification
+	}
+
+	tests := []test{
+		{
+			"renders all fields",
+			map[string]any{"name": "Jane", "count": 3, "inbox": "a1"},
+			false,
+			&RenderedNotification{
+				Title: "Hello Jane",
+				Body:  "You have 3 new messages",
+				URL:   "https://example.com/inbox/a1",
+				Data: map[string]any{
+					"inbox": "a1",
+					"tags":  []any{"Jane", float64(42)},
+				},
+			},
+		},
+		{
+			"fails on missing variable",
+			map[string]any{"name": "Jane"},
+			true,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := tpl.Render(tt.variables)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			got := &RenderedNotification{}
+
+			if err = json.Unmarshal(buf, got); err != nil {
+				t.Fatalf("failed to decode rendered template: %v", err)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTemplateValidate(t *testing.T) {
+	valid := NotificationTemplate{ClientId: TEST_CLIENT_ID, TemplateId: "test", Title: "Hello {{.name}}"}
+	invalid := NotificationTemplate{ClientId: TEST_CLIENT_ID, TemplateId: "test", Title: "Hello {{.name"}
+
+	assert.NoError(t, valid.Validate())
+	assert.Error(t, invalid.Validate())
+
+	valid.Data = map[string]any{"link": "/{{.id}}", "nested": map[string]any{"items": []any{"{{.name}}", 42}}}
+	assert.NoError(t, valid.Validate())
+
+	invalid = valid
+	invalid.Data = map[string]any{"nested": map[string]any{"items": []any{"{{.name"}}}
+	assert.Error(t, invalid.Validate())
+}
+
+func TestTemplateSaveConcurrently(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := webpush_test.CreateContainer(ctx, t)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	defer container.Terminate(ctx)
+
+	conn, err := db.Connect()
+	assert.NoError(t, err)
+
+	defer conn.Close()
+
+	const saves = 8
+
+	var wg sync.WaitGroup
+	errs := make([]error, saves)
+
+	for i := range saves {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			tpl := &NotificationTemplate{
+				ClientId:   TEST_CLIENT_ID,
+				TemplateId: "concurrent",
+				Title:      fmt.Sprintf("Version %d", i),
+			}
+
+			errs[i] = tpl.Save(ctx, conn)
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	versions, err := GetTemplateVersions(ctx, conn, TEST_CLIENT_ID, "concurrent")
+	assert.NoError(t, err)
+	assert.Len(t, versions, saves)
+}
@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+var (
+	// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate doesn't cover, see https://datatracker.ietf.org/doc/html/rfc6598
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+	// reservedPrefixes are further special-purpose ranges, which aren't reachable on the public internet, see https://www.iana.org/assignments/iana-ipv4-special-registry
+	reservedPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		// local-use NAT64, see https://datatracker.ietf.org/doc/html/rfc8215
+		netip.MustParsePrefix("64:ff9b:1::/48"),
+	}
+
+	// nat64Prefix embeds an IPv4 address in its last 32 bits, see https://datatracker.ietf.org/doc/html/rfc6052
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+	// sixToFourPrefix embeds an IPv4 address in the 32 bits following the prefix, see https://datatracker.ietf.org/doc/html/rfc3056
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+)
+
+// embeddedIPv4 returns the IPv4 address, which NAT64 and 6to4 addresses translate to.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[12:16])), true
+	case sixToFourPrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[2:6])), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// Policy restricts the endpoints, which subscriptions may be stored for and push messages may be sent to.
+// By default, only HTTPS endpoints of registered push services on public IPs are allowed.
+type Policy struct {
+	AllowInsecure            bool
+	AllowPrivateIPs          bool
+	AllowUnknownPushServices bool
+}
+
+func getBoolEnv(env string) bool {
+	value, err := strconv.ParseBool(os.Getenv(env))
+
+	return err == nil && value
+}
+
+// GetPolicy returns the endpoint policy configured by the ENDPOINT_ALLOW_* env.
+func GetPolicy() *Policy {
+	return &Policy{
+		AllowInsecure:            getBoolEnv(utils.ENDPOINT_ALLOW_INSECURE_ENV),
+		AllowPrivateIPs:          getBoolEnv(utils.ENDPOINT_ALLOW_PRIVATE_IPS_ENV),
+		AllowUnknownPushServices: getBoolEnv(utils.ENDPOINT_ALLOW_UNKNOWN_PUSH_SERVICES_ENV),
+	}
+}
+
+// IsBlockedIP reports whether the IP address belongs to a private, loopback, link-local or otherwise non-public network.
+// NAT64 and 6to4 addresses are blocked, when the IPv4 address they translate to is.
+func IsBlockedIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if ipv4, ok := embeddedIPv4(addr); ok && IsBlockedIP(ipv4) {
+		return true
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return !addr.IsValid() ||
+		addr.IsUnspecified() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		sharedAddressSpace.Contains(addr)
+}
+
+// Validate checks the endpoint against the policy, without resolving its host.
+func (p *Policy) Validate(endpoint string) (err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("endpoint is not a valid URL: %w", err)
+	}
+
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && p.AllowInsecure:
+	default:
+		return fmt.Errorf("endpoint must use HTTPS, got %q", u.Scheme)
+	}
+
+	host := u.Hostname()
+
+	if host == "" {
+		return fmt.Errorf("endpoint must contain a host")
+	}
+
+	if !p.AllowUnknownPushServices && ForEndpoint(endpoint).Name == UNKNOWN {
+		return fmt.Errorf("endpoint host %q does not belong to a known push service", host)
+	}
+
+	if p.AllowPrivateIPs {
+		return
+	}
+
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("endpoint host %q must not be a loopback address", host)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && IsBlockedIP(addr) {
+		return fmt.Errorf("endpoint IP %s must be a public address", addr)
+	}
+
+	return
+}
+
+// control rejects connections to blocked IPs after DNS resolution, which prevents DNS rebinding attacks.
+func (p *Policy) control(network, address string, c syscall.RawConn) error {
+	if p.AllowPrivateIPs {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("failed to parse dialed address %q: %w", address, err)
+	}
+
+	if IsBlockedIP(addrPort.Addr()) {
+		return fmt.Errorf("dialing %s is blocked by the endpoint policy", addrPort.Addr())
+	}
+
+	return nil
+}
+
+// DialContext dials the address, enforcing the policy against the resolved IP address.
+func (p *Policy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+
+	return dialer.DialContext(ctx, network, address)
+}
+
+// CheckRedirect validates redirect targets against the policy, see http.Client.CheckRedirect.
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+
+	return p.Validate(req.URL.String())
+}
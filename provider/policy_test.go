@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	type test struct {
+		name     string
+		policy   Policy
+		endpoint string
+		wantErr  bool
+	}
+
+	tests := []test{
+		{"allows known push service", Policy{}, "https://fcm.googleapis.com/fcm/send/abc", false},
+		{"rejects plain HTTP", Policy{}, "http://fcm.googleapis.com/fcm/send/abc", true},
+		{"allows plain HTTP when opted in", Policy{AllowInsecure: true}, "http://fcm.googleapis.com/fcm/send/abc", false},
+		{"rejects other schemes", Policy{AllowInsecure: true}, "ftp://fcm.googleapis.com/abc", true},
+		{"rejects unknown push service", Policy{}, "https://push.example.com/abc", true},
+		{"allows unknown push service when opted in", Policy{AllowUnknownPushServices: true}, "https://push.example.com/abc", false},
+		{"rejects loopback IP", Policy{AllowUnknownPushServices: true}, "https://127.0.0.1/abc", true},
+		{"rejects private IP", Policy{AllowUnknownPushServices: true}, "https://10.0.0.1/abc", true},
+		{"rejects link-local IP", Policy{AllowUnknownPushServices: true}, "https://169.254.169.254/latest", true},
+		{"rejects IPv6 loopback", Policy{AllowUnknownPushServices: true}, "https://[::1]/abc", true},
+		{"rejects IPv4-mapped IPv6", Policy{AllowUnknownPushServices: true}, "https://[::ffff:127.0.0.1]/abc", true},
+		{"rejects localhost", Policy{AllowUnknownPushServices: true}, "https://localhost/abc", true},
+		{"allows public IP", Policy{AllowUnknownPushServices: true}, "https://8.8.8.8/abc", false},
+		{"allows private IP when opted in", Policy{AllowUnknownPushServices: true, AllowPrivateIPs: true}, "https://127.0.0.1/abc", false},
+		{"rejects missing host", Policy{AllowUnknownPushServices: true}, "https:///abc", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.endpoint)
+
+			assert.Equal(t, err != nil, tt.wantErr, "err = %v", err)
+		})
+	}
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	type test struct {
+		addr string
+		want bool
+	}
+
+	tests := []test{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"0.1.2.3", true},
+		{"192.0.0.8", true},
+		{"198.18.0.1", true},
+		{"198.19.255.255", true},
+		{"240.0.0.1", true},
+		{"255.255.255.255", true},
+		{"64:ff9b::7f00:1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::808:808", false},
+		{"64:ff9b:1::808:808", true},
+		{"2002:7f00:1::", true},
+		{"2002:a00:1::1", true},
+		{"2002:808:808::1", false},
+		{"8.8.8.8", false},
+		{"198.20.0.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, IsBlockedIP(netip.MustParseAddr(tt.addr)), tt.want)
+		})
+	}
+}
+
+func TestPolicyDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	t.Cleanup(server.Close)
+
+	addr := server.Listener.Addr().String()
+
+	t.Run("blocks private IPs at dial time", func(t *testing.T) {
+		policy := &Policy{}
+
+		_, err := policy.DialContext(context.Background(), "tcp", addr)
+
+		assert.ErrorContains(t, err, "blocked by the endpoint policy")
+	})
+
+	t.Run("dials private IPs when opted in", func(t *testing.T) {
+		policy := &Policy{AllowPrivateIPs: true}
+
+		conn, err := policy.DialContext(context.Background(), "tcp", addr)
+
+		assert.NilError(t, err)
+		assert.Assert(t, conn.(*net.TCPConn) != nil)
+
+		conn.Close()
+	})
+}
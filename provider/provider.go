@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const (
+	FCM      = "fcm"
+	AUTOPUSH = "autopush"
+	WNS      = "wns"
+	APPLE    = "apple"
+	UNKNOWN  = "unknown"
+)
+
+const MAX_TTL_VALUE int64 = 2147483648 // see https://datatracker.ietf.org/doc/html/rfc8030#section-5.2
+
+var urgencies = []string{"very-low", "low", "normal", "high"} // see https://datatracker.ietf.org/doc/html/rfc8030#section-5.3
+
+// Provider describes a push service, how to recognize its endpoints and how to shape requests sent to it.
+type Provider struct {
+	Name string
+
+	// Hosts matches the endpoint's host exactly, or any of its subdomains.
+	Hosts []string
+
+	// MaxTTL clamps the TTL header to the longest retention the push service supports.
+	MaxTTL int64
+
+	// NoTopic drops the Topic header for push services, which don't support replacing pending messages.
+	NoTopic bool
+
+	// Urgencies maps the urgencies of RFC 8030 to the ones the push service accepts, unmapped urgencies are dropped.
+	// Nil accepts all urgencies of RFC 8030.
+	Urgencies map[string]string
+
+	// Header contains additional headers, which are only sent to this push service.
+	Header http.Header
+}
+
+// Matches reports whether the host belongs to the push service.
+func (p *Provider) Matches(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, h := range p.Hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TTL clamps the given TTL to the limits of the push service.
+func (p *Provider) TTL(ttl int64) int64 {
+	maxTTL := p.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = MAX_TTL_VALUE
+	}
+
+	return max(0, min(ttl, maxTTL))
+}
+
+// Topic returns the topic, or an empty string, if the push service doesn't support it or would reject it.
+func (p *Provider) Topic(topic string) string {
+	if p.NoTopic || !utils.TopicRegex.MatchString(topic) {
+		return ""
+	}
+
+	return topic
+}
+
+// Urgency normalizes the urgency to one the push service accepts, or returns an empty string to drop it.
+func (p *Provider) Urgency(urgency string) string {
+	urgency = strings.ToLower(strings.TrimSpace(urgency))
+
+	if !slices.Contains(urgencies, urgency) {
+		return ""
+	}
+
+	if p.Urgencies == nil {
+		return urgency
+	}
+
+	return p.Urgencies[urgency]
+}
+
+// Apply adds the TTL, Topic and Urgency headers within the limits of the push service, as well as its own headers.
+func (p *Provider) Apply(header http.Header, ttl int64, topic, urgency string) {
+	header.Set("TTL", fmt.Sprintf("%d", p.TTL(ttl)))
+
+	if topic = p.Topic(topic); topic != "" {
+		header.Set("Topic", topic)
+	}
+
+	if urgency = p.Urgency(urgency); urgency != "" {
+		header.Set("Urgency", urgency)
+	}
+
+	for key, values := range p.Header {
+		header[key] = values
+	}
+}
+
+func (p Provider) String() string {
+	return p.Name
+}
+
+var (
+	mu sync.RWMutex
+
+	unknown = &Provider{Name: UNKNOWN}
+
+	registry = []*Provider{
+		{
+			Name:   FCM,
+			Hosts:  []string{"fcm.googleapis.com", "android.googleapis.com"},
+			MaxTTL: 2419200, // 4 weeks
+		},
+		{
+			Name:   AUTOPUSH,
+			Hosts:  []string{"push.services.mozilla.com"},
+			MaxTTL: 5184000, // 60 days
+		},
+		{
+			Name:  WNS,
+			Hosts: []string{"notify.windows.com"},
+			// WNS replaces pending notifications by its own X-WNS-Tag header instead
+			NoTopic: true,
+			Header: http.Header{
+				"X-WNS-Type":         {"wns/raw"},
+				"X-WNS-Cache-Policy": {"cache"},
+			},
+		},
+		{
+			Name:  APPLE,
+			Hosts: []string{"web.push.apple.com"},
+		},
+	}
+)
+
+// Register adds a push service to the registry, taking precedence over the already registered ones.
+func Register(p *Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registry = append([]*Provider{p}, registry...)
+}
+
+// ForEndpoint classifies the endpoint by its host, falling back to the unknown provider.
+func ForEndpoint(endpoint string) *Provider {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return unknown
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, p := range registry {
+		if p.Matches(u.Hostname()) {
+			return p
+		}
+	}
+
+	return unknown
+}
@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestForEndpoint(t *testing.T) {
+	type test struct {
+		name     string
+		endpoint string
+		want     string
+	}
+
+	tests := []test{
+		{"classifies FCM", "https://fcm.googleapis.com/fcm/send/abc", FCM},
+		{"classifies legacy GCM", "https://android.googleapis.com/gcm/send/abc", FCM},
+		{"classifies autopush", "https://updates.push.services.mozilla.com/wpush/v2/abc", AUTOPUSH},
+		{"classifies WNS", "https://wns2-par02p.notify.windows.com/w/?token=abc", WNS},
+		{"classifies Apple", "https://web.push.apple.com/abc", APPLE},
+		{"is case-insensitive", "https://FCM.googleapis.com/fcm/send/abc", FCM},
+		{"does not match lookalike hosts", "https://fcm.googleapis.com.example.com/abc", UNKNOWN},
+		{"does not match host suffixes without subdomain", "https://evilnotify.windows.com/abc", UNKNOWN},
+		{"falls back to unknown", "https://push.example.com/abc", UNKNOWN},
+		{"falls back to unknown on malformed endpoint", "://", UNKNOWN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ForEndpoint(tt.endpoint).Name, tt.want)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	type test struct {
+		name       string
+		endpoint   string
+		ttl        int64
+		wantTTL    string
+		wantWNS    bool
+		wantTopic  string
+		wantUrgent string
+	}
+
+	tests := []test{
+		{
+			name:       "only sends WNS headers to WNS",
+			endpoint:   "https://wns2-par02p.notify.windows.com/w/?token=abc",
+			ttl:        60,
+			wantTTL:    "60",
+			wantWNS:    true,
+			wantTopic:  "",
+			wantUrgent: "high",
+		},
+		{
+			name:       "clamps TTL for FCM",
+			endpoint:   "https://fcm.googleapis.com/fcm/send/abc",
+			ttl:        31536000,
+			wantTTL:    "2419200",
+			wantTopic:  "news",
+			wantUrgent: "high",
+		},
+		{
+			name:       "does not send WNS headers to Apple",
+			endpoint:   "https://web.push.apple.com/abc",
+			ttl:        -1,
+			wantTTL:    "0",
+			wantTopic:  "news",
+			wantUrgent: "high",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+
+			ForEndpoint(tt.endpoint).Apply(header, tt.ttl, "news", "high")
+
+			assert.Equal(t, header.Get("TTL"), tt.wantTTL)
+			assert.Equal(t, header.Get("Topic"), tt.wantTopic)
+			assert.Equal(t, header.Get("Urgency"), tt.wantUrgent)
+			// the WNS headers are sent exactly as registered, without canonicalization
+			assert.Equal(t, len(header["X-WNS-Type"]) > 0, tt.wantWNS)
+			assert.Equal(t, len(header["X-WNS-Cache-Policy"]) > 0, tt.wantWNS)
+		})
+	}
+}
+
+func TestTopicAndUrgency(t *testing.T) {
+	type test struct {
+		name        string
+		endpoint    string
+		topic       string
+		urgency     string
+		wantTopic   string
+		wantUrgency string
+	}
+
+	tests := []test{
+		{"keeps topic and urgency for FCM", "https://fcm.googleapis.com/fcm/send/abc", "news", "very-low", "news", "very-low"},
+		{"keeps topic and urgency for autopush", "https://updates.push.services.mozilla.com/wpush/v2/abc", "news", "low", "news", "low"},
+		{"keeps topic and urgency for Apple", "https://web.push.apple.com/abc", "news", "high", "news", "high"},
+		{"keeps topic and urgency for unknown push services", "https://push.example.com/abc", "news", "normal", "news", "normal"},
+		{"drops topic for WNS", "https://wns2-par02p.notify.windows.com/w/?token=abc", "news", "high", "", "high"},
+		{"drops topics longer than 32 characters", "https://fcm.googleapis.com/fcm/send/abc", "abcdefghijklmnopqrstuvwxyz0123456", "high", "", "high"},
+		{"drops topics outside the URL-safe base64 alphabet", "https://web.push.apple.com/abc", "breaking news", "high", "", "high"},
+		{"normalizes the case of urgencies", "https://updates.push.services.mozilla.com/wpush/v2/abc", "news", " High ", "news", "high"},
+		{"drops unknown urgencies", "https://web.push.apple.com/abc", "news", "urgent", "news", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+
+			ForEndpoint(tt.endpoint).Apply(header, 60, tt.topic, tt.urgency)
+
+			assert.Equal(t, header.Get("Topic"), tt.wantTopic)
+			assert.Equal(t, header.Get("Urgency"), tt.wantUrgency)
+			_, hasTopic := header["Topic"]
+			assert.Equal(t, hasTopic, tt.wantTopic != "")
+		})
+	}
+
+	t.Run("maps urgencies of registered push services", func(t *testing.T) {
+		p := &Provider{Name: "custom", Urgencies: map[string]string{"very-low": "low", "low": "low", "normal": "normal"}}
+
+		assert.Equal(t, p.Urgency("very-low"), "low")
+		assert.Equal(t, p.Urgency("normal"), "normal")
+		assert.Equal(t, p.Urgency("high"), "")
+	})
+}
+
+func TestRegister(t *testing.T) {
+	registered := registry
+	t.Cleanup(func() {
+		registry = registered
+	})
+
+	Register(&Provider{Name: "custom", Hosts: []string{"push.example.com"}, MaxTTL: 60})
+
+	p := ForEndpoint("https://eu.push.example.com/abc")
+
+	assert.Equal(t, p.Name, "custom")
+	assert.Equal(t, p.TTL(3600), int64(60))
+}
@@ -0,0 +1,312 @@
+// Package pushtest provides a fake RFC 8030 push service for integration tests.
+// It mints subscriptions, verifies the VAPID authorization of incoming push messages,
+// decrypts their aes128gcm-encoded bodies and exposes the received messages to the test.
+package pushtest
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const (
+	PUSH_PATH = "/push/"
+
+	MAX_BODY_SIZE       = 4096
+	MAX_VAPID_LIFETIME  = 24 * time.Hour // see https://datatracker.ietf.org/doc/html/rfc8292#section-2
+	DEFAULT_RETRY_AFTER = "60"
+)
+
+// Message is a push message received by the fake push service.
+type Message struct {
+	SubscriptionId string
+	Header         http.Header
+	TTL            int64
+	Topic          string
+	Urgency        string
+	Subject        string // the VAPID JWT's sub claim, e.g. mailto:test@example.com
+	Payload        []byte // the decrypted plaintext without padding
+}
+
+// Server is an httptest-based push service, accepting push messages for the subscriptions minted by NewSubscription.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	count         int
+	subscriptions map[string]*Subscription
+	responses     map[string][]int
+	messages      []*Message
+	rejected      []error
+}
+
+// NewServer starts a fake push service, which is closed on test cleanup.
+// It also relaxes the endpoint policy, as the push service listens on plain HTTP on a loopback address.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	t.Setenv(utils.ENDPOINT_ALLOW_INSECURE_ENV, "true")
+	t.Setenv(utils.ENDPOINT_ALLOW_PRIVATE_IPS_ENV, "true")
+	t.Setenv(utils.ENDPOINT_ALLOW_UNKNOWN_PUSH_SERVICES_ENV, "true")
+
+	s := &Server{
+		subscriptions: make(map[string]*Subscription),
+		responses:     make(map[string][]int),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handlePush))
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// NewSubscription mints a new subscription with freshly generated p256dh and auth keys.
+func (s *Server) NewSubscription() (sub *Subscription, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+	id := strconv.Itoa(s.count)
+
+	if sub, err = newSubscription(id, s.URL+PUSH_PATH+id); err != nil {
+		return
+	}
+
+	s.subscriptions[id] = sub
+
+	return
+}
+
+// RespondWith makes the push service answer the next push messages for the subscription with the given status codes, in order,
+// e.g. http.StatusGone to simulate an expired subscription. Afterwards, push messages are accepted again.
+func (s *Server) RespondWith(sub *Subscription, statusCodes ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[sub.Id] = append(s.responses[sub.Id], statusCodes...)
+}
+
+// Messages returns all push messages received so far.
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Message{}, s.messages...)
+}
+
+// MessagesFor returns the push messages received for the subscription.
+func (s *Server) MessagesFor(sub *Subscription) (messages []*Message) {
+	for _, msg := range s.Messages() {
+		if msg.SubscriptionId == sub.Id {
+			messages = append(messages, msg)
+		}
+	}
+
+	return
+}
+
+// Rejected returns the reasons of all push messages, which failed validation, e.g. due to an invalid VAPID JWT or encryption.
+func (s *Server) Rejected() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]error{}, s.rejected...)
+}
+
+// Reset clears the received messages, rejections and pending responses.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+	s.rejected = nil
+	s.responses = make(map[string][]int)
+}
+
+func (s *Server) reject(w http.ResponseWriter, statusCode int, err error) {
+	s.mu.Lock()
+	s.rejected = append(s.rejected, err)
+	s.mu.Unlock()
+
+	http.Error(w, err.Error(), statusCode)
+}
+
+// nextResponse pops the next injected status code of the subscription, if any.
+func (s *Server) nextResponse(id string) (statusCode int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.responses[id]) == 0 {
+		return
+	}
+
+	statusCode = s.responses[id][0]
+	s.responses[id] = s.responses[id][1:]
+
+	return statusCode, true
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, PUSH_PATH)
+
+	s.mu.Lock()
+	sub, ok := s.subscriptions[id]
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+
+	if statusCode, ok := s.nextResponse(id); ok {
+		if statusCode == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", DEFAULT_RETRY_AFTER)
+		}
+
+		http.Error(w, http.StatusText(statusCode), statusCode)
+		return
+	}
+
+	subject, err := s.verifyVAPID(r.Header.Get("Authorization"))
+	if err != nil {
+		s.reject(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	ttl, err := strconv.ParseInt(r.Header.Get("TTL"), 10, 64)
+	if err != nil || ttl < 0 {
+		s.reject(w, http.StatusBadRequest, fmt.Errorf("invalid TTL header %q", r.Header.Get("TTL")))
+		return
+	}
+
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "aes128gcm" {
+		s.reject(w, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding %q", encoding))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MAX_BODY_SIZE+1))
+	if err != nil {
+		s.reject(w, http.StatusBadRequest, fmt.Errorf("failed to read body: %w", err))
+		return
+	}
+
+	if len(body) > MAX_BODY_SIZE {
+		s.reject(w, http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", MAX_BODY_SIZE))
+		return
+	}
+
+	payload, err := sub.decrypt(body)
+	if err != nil {
+		s.reject(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, &Message{
+		SubscriptionId: id,
+		Header:         r.Header.Clone(),
+		TTL:            ttl,
+		Topic:          r.Header.Get("Topic"),
+		Urgency:        r.Header.Get("Urgency"),
+		Subject:        subject,
+		Payload:        payload,
+	})
+	messageId := len(s.messages)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("%s/message/%d", s.URL, messageId))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// verifyVAPID checks the "vapid t=<jwt>,k=<key>" authorization, see https://datatracker.ietf.org/doc/html/rfc8292#section-3
+func (s *Server) verifyVAPID(authorization string) (subject string, err error) {
+	scheme, params, ok := strings.Cut(authorization, " ")
+
+	if !ok || !strings.EqualFold(scheme, "vapid") {
+		return "", fmt.Errorf("missing vapid authorization")
+	}
+
+	var token, key string
+
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+
+	var publicKey *ecdsa.PublicKey
+
+	if publicKey, err = decodeVAPIDKey(key); err != nil {
+		return
+	}
+
+	claims := jwt.MapClaims{}
+
+	if _, err = jwt.ParseWithClaims(
+		token,
+		claims,
+		func(*jwt.Token) (interface{}, error) { return publicKey, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithAudience(s.URL),
+		jwt.WithExpirationRequired(),
+	); err != nil {
+		return "", fmt.Errorf("invalid VAPID JWT: %w", err)
+	}
+
+	exp, _ := claims.GetExpirationTime()
+
+	if time.Until(exp.Time) > MAX_VAPID_LIFETIME {
+		return "", fmt.Errorf("VAPID JWT expires more than %s in the future", MAX_VAPID_LIFETIME)
+	}
+
+	if subject, _ = claims.GetSubject(); !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https:") {
+		return "", fmt.Errorf("VAPID JWT subject %q is neither a mailto: nor an https: URI", subject)
+	}
+
+	return
+}
+
+func decodeVAPIDKey(key string) (publicKey *ecdsa.PublicKey, err error) {
+	var buf []byte
+
+	if buf, err = base64.RawURLEncoding.DecodeString(key); err != nil {
+		return nil, fmt.Errorf("failed to decode VAPID public key: %w", err)
+	}
+
+	// validates the uncompressed point, before converting it to an ECDSA public key
+	if _, err = ecdh.P256().NewPublicKey(buf); err != nil {
+		return nil, fmt.Errorf("VAPID public key is no valid P-256 public key: %w", err)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(buf[1:33]),
+		Y:     new(big.Int).SetBytes(buf[33:]),
+	}, nil
+}
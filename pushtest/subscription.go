@@ -0,0 +1,93 @@
+package pushtest
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/saschazar21/go-web-push-server/webpush"
+)
+
+const AUTH_SECRET_SIZE = 16
+
+// Subscription is a push subscription minted by the fake push service, holding the user agent's private keys to decrypt incoming messages.
+type Subscription struct {
+	Id       string
+	Endpoint string
+
+	authSecret []byte
+	privateKey *ecdh.PrivateKey
+}
+
+// P256DH returns the base64url-encoded public key of the subscription, as exposed by PushSubscription.toJSON() in browsers.
+func (s *Subscription) P256DH() string {
+	return base64.RawURLEncoding.EncodeToString(s.privateKey.PublicKey().Bytes())
+}
+
+// Auth returns the base64url-encoded auth secret of the subscription.
+func (s *Subscription) Auth() string {
+	return base64.RawURLEncoding.EncodeToString(s.authSecret)
+}
+
+// RecipientSubscription returns the subscription in the format sent by browsers to the /api/v1/subscribe endpoint.
+func (s *Subscription) RecipientSubscription() *utils.RecipientSubscription {
+	return &utils.RecipientSubscription{
+		Endpoint: s.Endpoint,
+		Keys: &utils.RecipientKeys{
+			P256DH: s.P256DH(),
+			Auth:   s.Auth(),
+		},
+	}
+}
+
+// PushSubscription returns the subscription as database model for the given client and recipient.
+func (s *Subscription) PushSubscription(clientId, recipientId string) *models.PushSubscription {
+	endpoint := s.Endpoint
+	p256dh := s.privateKey.PublicKey().Bytes()
+	authSecret := append([]byte{}, s.authSecret...)
+
+	return &models.PushSubscription{
+		Endpoint:    (*utils.EncryptedString)(&endpoint),
+		ClientId:    clientId,
+		RecipientId: recipientId,
+		Keys: &models.SubscriptionKeys{
+			P256DH:     (*utils.EncryptedBytes)(&p256dh),
+			AuthSecret: (*utils.EncryptedBytes)(&authSecret),
+		},
+	}
+}
+
+// decrypt reverses the aes128gcm content coding, as done by the user agent.
+func (s *Subscription) decrypt(body []byte) (plaintext []byte, err error) {
+	var record *webpush.DecryptedRecord
+
+	if record, err = webpush.Decrypt(body, s.privateKey, s.authSecret); err != nil {
+		return
+	}
+
+	return record.Plaintext, nil
+}
+
+func newSubscription(id, endpoint string) (sub *Subscription, err error) {
+	var privateKey *ecdh.PrivateKey
+
+	if privateKey, err = ecdh.P256().GenerateKey(rand.Reader); err != nil {
+		return nil, fmt.Errorf("failed to generate subscription key pair: %w", err)
+	}
+
+	authSecret := make([]byte, AUTH_SECRET_SIZE)
+
+	if _, err = rand.Read(authSecret); err != nil {
+		return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+	}
+
+	return &Subscription{
+		Id:         id,
+		Endpoint:   endpoint,
+		authSecret: authSecret,
+		privateKey: privateKey,
+	}, nil
+}
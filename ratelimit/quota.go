@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const (
+	QUOTA_DAILY   = "daily"
+	QUOTA_MONTHLY = "monthly"
+)
+
+// Quota limits the amount of delivered push notifications per client and day or month (UTC).
+type Quota struct {
+	Name  string
+	Limit int64
+}
+
+// Period returns the identifier of the period containing the given time, e.g. 2006-01-02 or 2006-01.
+func (q *Quota) Period(now time.Time) string {
+	now = now.UTC()
+
+	if q.Name == QUOTA_MONTHLY {
+		return now.Format("2006-01")
+	}
+
+	return now.Format(time.DateOnly)
+}
+
+// Reset returns the start of the period following the given time.
+func (q *Quota) Reset(now time.Time) time.Time {
+	now = now.UTC()
+
+	if q.Name == QUOTA_MONTHLY {
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// GetQuotas returns the quotas configured by the QUOTA_DAILY and QUOTA_MONTHLY env.
+func GetQuotas() (quotas []*Quota) {
+	for name, env := range map[string]string{QUOTA_DAILY: utils.QUOTA_DAILY_ENV, QUOTA_MONTHLY: utils.QUOTA_MONTHLY_ENV} {
+		value := os.Getenv(env)
+
+		if value == "" {
+			continue
+		}
+
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			log.Printf("%s env must be a positive integer > 0, the %s quota is disabled\n", env, name)
+			continue
+		}
+
+		quotas = append(quotas, &Quota{Name: name, Limit: limit})
+	}
+
+	return
+}
+
+// CheckQuota returns a 429 error, if delivering the given amount of push notifications would exceed any of the client's quotas.
+func CheckQuota(ctx context.Context, store Store, clientId string, targets int) (err error) {
+	now := time.Now().UTC()
+
+	for _, quota := range GetQuotas() {
+		var delivered int64
+
+		if delivered, err = store.Usage(ctx, clientId, quota.Period(now)); err != nil {
+			return
+		}
+
+		if delivered+int64(targets) <= quota.Limit {
+			continue
+		}
+
+		log.Printf("client: %s would exceed its %s quota of %d push notifications with %d more", clientId, quota.Name, quota.Limit, targets)
+
+		header := http.Header{}
+		header.Set(RETRY_AFTER_HEADER, seconds(quota.Reset(now).Sub(now)))
+
+		return newTooManyRequestsError(header, fmt.Sprintf("The %s quota of %d push notifications has %d remaining, but %d were targeted", quota.Name, quota.Limit, max(quota.Limit-delivered, 0), targets))
+	}
+
+	return
+}
+
+// AddDeliveries counts the delivered push notifications towards all quotas of the client.
+func AddDeliveries(ctx context.Context, store Store, clientId string, delivered int) (err error) {
+	if delivered <= 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+
+	for _, quota := range GetQuotas() {
+		if err = store.AddUsage(ctx, clientId, quota.Period(now), int64(delivered)); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	now := time.Date(2026, time.December, 31, 23, 30, 0, 0, time.UTC)
+
+	daily := &Quota{Name: QUOTA_DAILY}
+	assert.Equal(t, daily.Period(now), "2026-12-31")
+	assert.Equal(t, daily.Reset(now), time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	monthly := &Quota{Name: QUOTA_MONTHLY}
+	assert.Equal(t, monthly.Period(now), "2026-12")
+	assert.Equal(t, monthly.Reset(now), time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestCheckQuota(t *testing.T) {
+	ctx := context.Background()
+
+	t.Setenv(utils.QUOTA_DAILY_ENV, "3")
+	t.Setenv(utils.QUOTA_MONTHLY_ENV, "100")
+
+	store := NewMemoryStore()
+
+	assert.NilError(t, CheckQuota(ctx, store, "client", 3))
+	assert.Assert(t, CheckQuota(ctx, store, "client", 4) != nil)
+	assert.NilError(t, AddDeliveries(ctx, store, "client", 2))
+	assert.NilError(t, CheckQuota(ctx, store, "client", 1))
+	assert.Assert(t, CheckQuota(ctx, store, "client", 2) != nil)
+	assert.NilError(t, AddDeliveries(ctx, store, "client", 1))
+
+	err := CheckQuota(ctx, store, "client", 1)
+	responseErr, ok := err.(errors.ResponseError)
+
+	assert.Assert(t, ok)
+	assert.Equal(t, responseErr.StatusCode, 429)
+	assert.Assert(t, responseErr.Headers.Get(RETRY_AFTER_HEADER) != "")
+
+	assert.NilError(t, CheckQuota(ctx, store, "other", 3))
+
+	t.Setenv(utils.QUOTA_DAILY_ENV, "")
+	assert.NilError(t, CheckQuota(ctx, store, "client", 1))
+	assert.Assert(t, CheckQuota(ctx, store, "client", 98) != nil)
+}
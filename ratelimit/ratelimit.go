@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const (
+	RATELIMIT_LIMIT_HEADER     = "RateLimit-Limit"
+	RATELIMIT_REMAINING_HEADER = "RateLimit-Remaining"
+	RATELIMIT_RESET_HEADER     = "RateLimit-Reset"
+	RETRY_AFTER_HEADER         = "Retry-After"
+)
+
+// ParseRateLimit parses a rate limit in the format <requests>/<window>, e.g. 60/1m for 60 requests per minute.
+func ParseRateLimit(s string) (limit *models.RateLimit, err error) {
+	requests, window, ok := strings.Cut(s, "/")
+
+	if !ok {
+		return nil, fmt.Errorf("rate limit %q must be in the format <requests>/<window>, e.g. 60/1m", s)
+	}
+
+	limit = &models.RateLimit{}
+
+	if limit.Requests, err = strconv.ParseInt(strings.TrimSpace(requests), 10, 64); err != nil || limit.Requests <= 0 {
+		return nil, fmt.Errorf("rate limit %q must allow a positive number of requests", s)
+	}
+
+	if limit.Window, err = time.ParseDuration(strings.TrimSpace(window)); err != nil || limit.Window <= 0 {
+		return nil, fmt.Errorf("rate limit %q must have a positive window, e.g. 1s, 1m or 1h", s)
+	}
+
+	return limit, nil
+}
+
+// GetRateLimit returns the rate limit of the given endpoint, e.g. RATE_LIMIT_PUSH, falling back to RATE_LIMIT.
+// A nil limit disables rate limiting.
+func GetRateLimit(endpoint string) *models.RateLimit {
+	for _, env := range []string{fmt.Sprintf("%s_%s", utils.RATE_LIMIT_ENV, strings.ToUpper(endpoint)), utils.RATE_LIMIT_ENV} {
+		value := os.Getenv(env)
+
+		if value == "" {
+			continue
+		}
+
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			log.Printf("failed to parse %s env, rate limiting is disabled: %v\n", env, err)
+			return nil
+		}
+
+		return limit
+	}
+
+	return nil
+}
+
+func bucketKey(clientId, endpoint string) string {
+	return fmt.Sprintf("%s:%s", endpoint, clientId)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d.Round(time.Second)/time.Second), 10)
+}
+
+// Header returns the RateLimit-* headers, as well as the Retry-After header for rejected requests.
+func Header(result *models.RateLimitResult) http.Header {
+	header := http.Header{}
+	header.Set(RATELIMIT_LIMIT_HEADER, strconv.FormatInt(result.Limit, 10))
+	header.Set(RATELIMIT_REMAINING_HEADER, strconv.FormatInt(result.Remaining, 10))
+	header.Set(RATELIMIT_RESET_HEADER, seconds(result.Reset))
+
+	if !result.Allowed {
+		retryAfter := result.RetryAfter
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+
+		header.Set(RETRY_AFTER_HEADER, seconds(retryAfter))
+	}
+
+	return header
+}
+
+func newTooManyRequestsError(header http.Header, detail string) error {
+	header.Set("Content-Type", utils.JSON_API)
+
+	payload := errors.NewErrorResponse(http.StatusTooManyRequests, "Too Many Requests", detail)
+
+	return errors.NewResponseError(payload, http.StatusTooManyRequests, header)
+}
+
+// NewRateLimitError returns a JSON:API 429 error carrying the RateLimit-* and Retry-After headers.
+func NewRateLimitError(result *models.RateLimitResult) error {
+	return newTooManyRequestsError(Header(result), fmt.Sprintf("Rate limit of %d requests exceeded, retry after %s seconds", result.Limit, Header(result).Get(RETRY_AFTER_HEADER)))
+}
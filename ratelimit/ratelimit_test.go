@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	type test struct {
+		name    string
+		value   string
+		want    *models.RateLimit
+		wantErr bool
+	}
+
+	tests := []test{
+		{
+			name:  "parses requests per minute",
+			value: "60/1m",
+			want:  &models.RateLimit{Requests: 60, Window: time.Minute},
+		},
+		{
+			name:  "parses requests per second with whitespace",
+			value: " 10 / 1s ",
+			want:  &models.RateLimit{Requests: 10, Window: time.Second},
+		},
+		{
+			name:    "rejects missing window",
+			value:   "60",
+			wantErr: true,
+		},
+		{
+			name:    "rejects zero requests",
+			value:   "0/1m",
+			wantErr: true,
+		},
+		{
+			name:    "rejects invalid window",
+			value:   "60/minute",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseRateLimit(tt.value)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+
+			if !tt.wantErr {
+				assert.DeepEqual(t, limit, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRateLimit(t *testing.T) {
+	t.Setenv(utils.RATE_LIMIT_ENV, "")
+	assert.Assert(t, GetRateLimit("push") == nil)
+
+	t.Setenv(utils.RATE_LIMIT_ENV, "60/1m")
+	assert.DeepEqual(t, GetRateLimit("push"), &models.RateLimit{Requests: 60, Window: time.Minute})
+
+	t.Setenv(utils.RATE_LIMIT_ENV+"_PUSH", "10/1s")
+	assert.DeepEqual(t, GetRateLimit("push"), &models.RateLimit{Requests: 10, Window: time.Second})
+	assert.DeepEqual(t, GetRateLimit("subscribe"), &models.RateLimit{Requests: 60, Window: time.Minute})
+
+	t.Setenv(utils.RATE_LIMIT_ENV+"_PUSH", "invalid")
+	assert.Assert(t, GetRateLimit("push") == nil)
+}
+
+func TestRateLimitBucket(t *testing.T) {
+	limit := &models.RateLimit{Requests: 2, Window: 10 * time.Second}
+	bucket := &models.RateLimitBucket{}
+	now := time.Now()
+
+	result := bucket.Take(limit, now)
+	assert.Assert(t, result.Allowed)
+	assert.Equal(t, result.Remaining, int64(1))
+
+	result = bucket.Take(limit, now)
+	assert.Assert(t, result.Allowed)
+	assert.Equal(t, result.Remaining, int64(0))
+	assert.Equal(t, result.Reset, 10*time.Second)
+
+	result = bucket.Take(limit, now.Add(time.Second))
+	assert.Assert(t, !result.Allowed)
+	assert.Equal(t, result.RetryAfter, 4*time.Second)
+
+	header := Header(result)
+	assert.Equal(t, header.Get(RATELIMIT_LIMIT_HEADER), "2")
+	assert.Equal(t, header.Get(RATELIMIT_REMAINING_HEADER), "0")
+	assert.Equal(t, header.Get(RETRY_AFTER_HEADER), "4")
+
+	result = bucket.Take(limit, now.Add(5*time.Second))
+	assert.Assert(t, result.Allowed)
+	assert.Equal(t, Header(result).Get(RETRY_AFTER_HEADER), "")
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const (
+	STORE_MEMORY   = "memory"
+	STORE_POSTGRES = "postgres"
+)
+
+// Store keeps the token buckets and the quota usage of all clients.
+type Store interface {
+	Take(ctx context.Context, key string, limit *models.RateLimit) (*models.RateLimitResult, error)
+	Usage(ctx context.Context, clientId, period string) (int64, error)
+	AddUsage(ctx context.Context, clientId, period string, delivered int64) error
+}
+
+// memoryStore keeps the state within the current process, which only suits single nodes.
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*models.RateLimitBucket
+	usage   map[string]int64
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit *models.RateLimit) (*models.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &models.RateLimitBucket{Key: key}
+		s.buckets[key] = bucket
+	}
+
+	return bucket.Take(limit, time.Now().UTC()), nil
+}
+
+func (s *memoryStore) Usage(ctx context.Context, clientId, period string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.usage[bucketKey(clientId, period)], nil
+}
+
+func (s *memoryStore) AddUsage(ctx context.Context, clientId, period string, delivered int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.usage[bucketKey(clientId, period)] += delivered
+
+	return nil
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: map[string]*models.RateLimitBucket{},
+		usage:   map[string]int64{},
+	}
+}
+
+// postgresStore shares the state between multiple nodes.
+type postgresStore struct {
+	db bun.IDB
+}
+
+func (s *postgresStore) Take(ctx context.Context, key string, limit *models.RateLimit) (*models.RateLimitResult, error) {
+	return models.TakeRateLimitToken(ctx, s.db, key, limit)
+}
+
+func (s *postgresStore) Usage(ctx context.Context, clientId, period string) (int64, error) {
+	return models.GetQuotaUsage(ctx, s.db, clientId, period)
+}
+
+func (s *postgresStore) AddUsage(ctx context.Context, clientId, period string, delivered int64) error {
+	return models.AddQuotaUsage(ctx, s.db, clientId, period, delivered)
+}
+
+func NewPostgresStore(db bun.IDB) Store {
+	return &postgresStore{db}
+}
+
+var defaultMemoryStore = NewMemoryStore()
+
+// UsesPostgres reports whether the RATE_LIMIT_STORE env selects the Postgres store.
+func UsesPostgres() bool {
+	return os.Getenv(utils.RATE_LIMIT_STORE_ENV) == STORE_POSTGRES
+}
+
+// NewStore returns the store selected by the RATE_LIMIT_STORE env, defaulting to the in-memory store.
+// The database connection is only used by the Postgres store.
+func NewStore(db bun.IDB) Store {
+	switch store := os.Getenv(utils.RATE_LIMIT_STORE_ENV); store {
+	case STORE_POSTGRES:
+		return NewPostgresStore(db)
+	case "", STORE_MEMORY:
+		return defaultMemoryStore
+	default:
+		log.Printf("unknown %s env %q, falling back to %s\n", utils.RATE_LIMIT_STORE_ENV, store, STORE_MEMORY)
+		return defaultMemoryStore
+	}
+}
+
+// Allow takes a token from the bucket of the client and endpoint.
+func Allow(ctx context.Context, store Store, clientId, endpoint string, limit *models.RateLimit) (*models.RateLimitResult, error) {
+	return store.Take(ctx, bucketKey(clientId, endpoint), limit)
+}
@@ -0,0 +1,96 @@
+package request
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const MAX_BATCH_ENTRIES = utils.MAX_PUSH_RECIPIENTS
+
+type BatchPushEntry struct {
+	RecipientId string          `json:"recipientId" validate:"required,max=255"`
+	Payload     json.RawMessage `json:"payload" validate:"required"`
+	TTL         *int64          `json:"ttl,omitempty" validate:"omitempty,gte=0"`
+	Topic       string          `json:"topic,omitempty" validate:"omitempty,topic"`
+	Urgency     string          `json:"urgency,omitempty" validate:"omitempty,oneof=very-low low normal high"`
+}
+
+// RawPayload returns the payload for raw push messages, JSON strings are sent as plain text.
+func (e *BatchPushEntry) RawPayload() []byte {
+	return decodeRawPayload(e.Payload)
+}
+
+// Params returns the web push parameters of the entry, falling back to the given defaults for omitted fields.
+func (e *BatchPushEntry) Params(defaults *WithWebPushParams) *WithWebPushParams {
+	params := &WithWebPushParams{}
+
+	if defaults != nil {
+		*params = *defaults
+	}
+
+	if e.TTL != nil {
+		params.TTL = *e.TTL
+	}
+
+	if e.Topic != "" {
+		params.Topic = e.Topic
+	}
+
+	if e.Urgency != "" {
+		params.Urgency = e.Urgency
+	}
+
+	return params
+}
+
+type BatchPushRequest struct {
+	Entries []*BatchPushEntry `validate:"required,min=1,max-recipients,dive,required"`
+}
+
+// RecipientIds returns the unique recipient IDs of all entries.
+func (r *BatchPushRequest) RecipientIds() (recipientIds []string) {
+	seen := make(map[string]bool, len(r.Entries))
+
+	for _, entry := range r.Entries {
+		if seen[entry.RecipientId] {
+			continue
+		}
+
+		seen[entry.RecipientId] = true
+		recipientIds = append(recipientIds, entry.RecipientId)
+	}
+
+	return
+}
+
+func (r *BatchPushRequest) Validate() (err error) {
+	if err = utils.CustomValidateStruct(r); err != nil {
+		log.Printf("validation of batch push request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+// ParseBatchPushRequest parses a JSON array of batch push entries.
+func ParseBatchPushRequest(body []byte) (r *BatchPushRequest, err error) {
+	r = &BatchPushRequest{}
+
+	if err = json.Unmarshal(body, &r.Entries); err != nil {
+		log.Println(err)
+
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "failed to parse JSON body")
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
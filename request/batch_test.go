@@ -0,0 +1,99 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchPushRequest(t *testing.T) {
+	type testCase struct {
+		name       string
+		body       string
+		wantErr    bool
+		recipients []string
+	}
+
+	tooMany := make([]string, MAX_BATCH_ENTRIES+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`{"recipientId":"recipient-%d","payload":"Hello"}`, i)
+	}
+
+	tests := []testCase{
+		{
+			name:       "should parse batch entries",
+			body:       `[{"recipientId":"a","payload":"You have 3 new messages","ttl":60},{"recipientId":"b","payload":{"count":1},"urgency":"high"},{"recipientId":"a","payload":"Reminder"}]`,
+			wantErr:    false,
+			recipients: []string{"a", "b"},
+		},
+		{
+			name:    "should return error on empty batch",
+			body:    `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on missing recipient ID",
+			body:    `[{"payload":"Hello"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on missing payload",
+			body:    `[{"recipientId":"a"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on invalid urgency",
+			body:    `[{"recipientId":"a","payload":"Hello","urgency":"urgent"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on negative TTL",
+			body:    `[{"recipientId":"a","payload":"Hello","ttl":-1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on too many entries",
+			body:    fmt.Sprintf("[%s]", strings.Join(tooMany, ",")),
+			wantErr: true,
+		},
+		{
+			name:    "should return error on JSON object",
+			body:    `{"recipientId":"a","payload":"Hello"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseBatchPushRequest([]byte(tc.body))
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := strings.Join(r.RecipientIds(), ","); got != strings.Join(tc.recipients, ",") {
+				t.Errorf("expected recipient IDs '%v', got '%v'", tc.recipients, got)
+			}
+		})
+	}
+}
+
+func TestBatchPushEntryParams(t *testing.T) {
+	ttl := int64(60)
+	defaults := &WithWebPushParams{TTL: 3600, Topic: "default", Urgency: "normal"}
+
+	entry := &BatchPushEntry{TTL: &ttl, Urgency: "high"}
+	params := entry.Params(defaults)
+
+	if params.TTL != 60 || params.Topic != "default" || params.Urgency != "high" {
+		t.Errorf("unexpected params: %+v", params)
+	}
+
+	if defaults.TTL != 3600 || defaults.Urgency != "normal" {
+		t.Errorf("defaults must not be modified: %+v", defaults)
+	}
+}
@@ -5,16 +5,22 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/saschazar21/go-web-push-server/errors"
 	"github.com/saschazar21/go-web-push-server/utils"
 )
 
-func ParseBody(req *http.Request, iface any) (err error) {
-	if req.Method != http.MethodPost {
+// ParseBody decodes the JSON request body into iface. The request method must equal one of the given methods, or POST, if omitted.
+func ParseBody(req *http.Request, iface any, methods ...string) (err error) {
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost}
+	}
+
+	if !slices.Contains(methods, req.Method) {
 		headers := map[string][]string{
-			http.CanonicalHeaderKey("allow"): {http.MethodPost},
+			http.CanonicalHeaderKey("allow"): methods,
 		}
 
 		return errors.NewResponseError(errors.METHOD_NOT_ALLOWED_ERROR, http.StatusMethodNotAllowed, headers)
@@ -0,0 +1,105 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+type LocalizedPayloads struct {
+	Default  string
+	Payloads map[string][]byte
+}
+
+func (p *LocalizedPayloads) Add(locale string, payload []byte) {
+	p.Payloads[strings.ToLower(locale)] = payload
+}
+
+// Select returns the best matching payload for the given locale, following the fallback chain
+// from the most specific locale to the default locale, e.g. de-AT -> de -> en.
+func (p *LocalizedPayloads) Select(locale string) []byte {
+	for _, l := range utils.LocaleFallbackChain(locale, p.Default) {
+		if payload, ok := p.Payloads[l]; ok {
+			return payload
+		}
+	}
+
+	return nil
+}
+
+func NewLocalizedPayloads(defaultLocale string) *LocalizedPayloads {
+	return &LocalizedPayloads{
+		Default:  strings.ToLower(defaultLocale),
+		Payloads: map[string][]byte{},
+	}
+}
+
+func NewPayload(payload []byte) *LocalizedPayloads {
+	p := NewLocalizedPayloads(utils.GetDefaultLocale())
+	p.Add(p.Default, payload)
+
+	return p
+}
+
+type LocalizedPushRequest struct {
+	Default  string                     `json:"default,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Payloads map[string]json.RawMessage `json:"payloads" validate:"required,min=1,dive,keys,bcp47_language_tag,endkeys,required"`
+}
+
+func (r *LocalizedPushRequest) Validate() (err error) {
+	if err = utils.CustomValidateStruct(r); err != nil {
+		log.Printf("validation of localized push request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func decodeRawPayload(raw json.RawMessage) []byte {
+	var text string
+
+	// JSON strings are sent as plain text, any other JSON value is sent as-is
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []byte(text)
+	}
+
+	return raw
+}
+
+func ParseLocalizedPushRequest(body []byte) (p *LocalizedPayloads, err error) {
+	r := &LocalizedPushRequest{}
+
+	if err = json.Unmarshal(body, r); err != nil {
+		log.Println(err)
+
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "failed to parse JSON body")
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+
+	if r.Default == "" {
+		r.Default = utils.GetDefaultLocale()
+	}
+
+	p = NewLocalizedPayloads(r.Default)
+
+	for locale, raw := range r.Payloads {
+		p.Add(locale, decodeRawPayload(raw))
+	}
+
+	if _, ok := p.Payloads[p.Default]; !ok {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "missing payload for default locale", fmt.Sprintf("a payload for the default locale %s is required", r.Default))
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
@@ -0,0 +1,74 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+func TestParseLocalizedPushRequest(t *testing.T) {
+	t.Setenv(utils.DEFAULT_LOCALE_ENV, "en")
+
+	type testCase struct {
+		name    string
+		body    string
+		wantErr bool
+		want    map[string]string
+	}
+
+	tests := []testCase{
+		{
+			name:    "should select best matching payload",
+			body:    `{"payloads":{"en":"Hello","de":"Hallo","de-AT":"Servus","fr":{"title":"Bonjour"}}}`,
+			wantErr: false,
+			want: map[string]string{
+				"de-AT": "Servus",
+				"de-DE": "Hallo",
+				"de":    "Hallo",
+				"fr-CA": `{"title":"Bonjour"}`,
+				"it":    "Hello",
+				"":      "Hello",
+			},
+		},
+		{
+			name:    "should use custom default locale",
+			body:    `{"default":"de","payloads":{"de":"Hallo","en-GB":"Hello"}}`,
+			wantErr: false,
+			want: map[string]string{
+				"en-GB": "Hello",
+				"en-US": "Hallo",
+			},
+		},
+		{
+			name:    "should return error on missing default payload",
+			body:    `{"payloads":{"de":"Hallo"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on invalid locale",
+			body:    `{"payloads":{"en":"Hello","not a locale":"Hallo"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on missing payloads",
+			body:    `{"default":"en"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			payloads, err := ParseLocalizedPushRequest([]byte(tc.body))
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			for locale, want := range tc.want {
+				if got := string(payloads.Select(locale)); got != want {
+					t.Errorf("expected payload for locale %s to be '%s', got '%s'", locale, want, got)
+				}
+			}
+		})
+	}
+}
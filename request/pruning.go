@@ -0,0 +1,41 @@
+package request
+
+import (
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/models"
+)
+
+// PruningPolicyRequest holds the body of PUT /api/v1/pruning.
+type PruningPolicyRequest struct {
+	Action      string `json:"action"`
+	MaxFailures int    `json:"maxFailures"`
+	WindowDays  int    `json:"windowDays"`
+	StatusCodes []int  `json:"statusCodes"`
+}
+
+func ParsePruningPolicyRequest(req *http.Request, clientId string) (policy *models.PruningPolicy, err error) {
+	r := &PruningPolicyRequest{}
+
+	if err = ParseBody(req, r, http.MethodPut); err != nil {
+		return nil, err
+	}
+
+	policy = &models.PruningPolicy{
+		ClientId:    clientId,
+		Action:      r.Action,
+		MaxFailures: r.MaxFailures,
+		WindowDays:  r.WindowDays,
+		StatusCodes: r.StatusCodes,
+	}
+
+	if policy.StatusCodes == nil {
+		policy.StatusCodes = []int{}
+	}
+
+	if err = policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
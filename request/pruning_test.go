@@ -0,0 +1,43 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+func TestParsePruningPolicyRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"should parse quarantine policy", `{"action":"quarantine","maxFailures":5,"windowDays":7,"statusCodes":[400,403,413]}`, false},
+		{"should parse delete policy without status codes", `{"action":"delete","maxFailures":3,"windowDays":1}`, false},
+		{"should reject unknown actions", `{"action":"archive","maxFailures":5,"windowDays":7}`, true},
+		{"should reject missing failures", `{"action":"delete","windowDays":7}`, true},
+		{"should reject invalid status codes", `{"action":"delete","maxFailures":5,"windowDays":7,"statusCodes":[600]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "https:///api/v1/pruning", strings.NewReader(tt.body))
+			req.Header.Set("content-type", utils.APPLICATION_JSON)
+
+			policy, err := ParsePruningPolicyRequest(req, "test client")
+
+			if tt.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, policy.ClientId, "test client")
+			assert.Assert(t, policy.StatusCodes != nil)
+		})
+	}
+}
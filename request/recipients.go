@@ -0,0 +1,49 @@
+package request
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const MAX_RECIPIENT_IDS = utils.MAX_PUSH_RECIPIENTS
+
+type RecipientsPushRequest struct {
+	RecipientIds []string        `json:"recipientIds" validate:"required,min=1,max-recipients,unique,dive,required,max=255"`
+	Payload      json.RawMessage `json:"payload" validate:"required"`
+}
+
+// RawPayload returns the payload for raw push messages, JSON strings are sent as plain text.
+func (r *RecipientsPushRequest) RawPayload() []byte {
+	return decodeRawPayload(r.Payload)
+}
+
+func (r *RecipientsPushRequest) Validate() (err error) {
+	if err = utils.CustomValidateStruct(r); err != nil {
+		log.Printf("validation of recipients push request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func ParseRecipientsPushRequest(body []byte) (r *RecipientsPushRequest, err error) {
+	r = &RecipientsPushRequest{}
+
+	if err = json.Unmarshal(body, r); err != nil {
+		log.Println(err)
+
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "failed to parse JSON body")
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
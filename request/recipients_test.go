@@ -0,0 +1,75 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseRecipientsPushRequest(t *testing.T) {
+	type testCase struct {
+		name    string
+		body    string
+		wantErr bool
+		want    string
+	}
+
+	tooMany := make([]string, MAX_RECIPIENT_IDS+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`"recipient-%d"`, i)
+	}
+
+	tests := []testCase{
+		{
+			name:    "should unwrap plain text payload",
+			body:    `{"recipientIds":["a","b"],"payload":"Hello"}`,
+			wantErr: false,
+			want:    "Hello",
+		},
+		{
+			name:    "should keep JSON payload",
+			body:    `{"recipientIds":["a"],"payload":{"title":"Hello"}}`,
+			wantErr: false,
+			want:    `{"title":"Hello"}`,
+		},
+		{
+			name:    "should return error on missing recipient IDs",
+			body:    `{"recipientIds":[],"payload":"Hello"}`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on duplicate recipient IDs",
+			body:    `{"recipientIds":["a","a"],"payload":"Hello"}`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on empty recipient ID",
+			body:    `{"recipientIds":[""],"payload":"Hello"}`,
+			wantErr: true,
+		},
+		{
+			name:    "should return error on too many recipient IDs",
+			body:    fmt.Sprintf(`{"recipientIds":[%s],"payload":"Hello"}`, strings.Join(tooMany, ",")),
+			wantErr: true,
+		},
+		{
+			name:    "should return error on missing payload",
+			body:    `{"recipientIds":["a"]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRecipientsPushRequest([]byte(tc.body))
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+
+			if err == nil && string(r.RawPayload()) != tc.want {
+				t.Errorf("expected payload '%s', got '%s'", tc.want, r.RawPayload())
+			}
+		})
+	}
+}
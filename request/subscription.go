@@ -7,6 +7,7 @@ import (
 
 	"github.com/saschazar21/go-web-push-server/errors"
 	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/provider"
 	"github.com/saschazar21/go-web-push-server/utils"
 )
 
@@ -32,34 +33,54 @@ func ParseSubscriptionRequest(req *http.Request) (sub *models.PushSubscription,
 		return sub, errors.NewResponseError(payload, http.StatusBadRequest)
 	}
 
-	decodedClientKey, err := base64.RawURLEncoding.DecodeString(r.Subscription.Keys.P256DH)
+	if r.Locale == "" {
+		r.Locale = utils.GetDefaultLocale()
+	}
+
+	if sub, err = newPushSubscription(r.Subscription); err != nil {
+		return nil, err
+	}
+
+	sub.ClientId = r.ClientId
+	sub.RecipientId = r.RecipientId
+	sub.Locale = r.Locale
+	sub.Tags = r.Tags
+
+	if err = sub.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// newPushSubscription applies the endpoint policy and decodes the keys of a subscription sent by a browser.
+func newPushSubscription(subscription *utils.RecipientSubscription) (sub *models.PushSubscription, err error) {
+	if err = provider.GetPolicy().Validate(subscription.Endpoint); err != nil {
+		log.Printf("subscription endpoint rejected: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid subscription endpoint", err.Error())
+		return sub, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	decodedClientKey, err := base64.RawURLEncoding.DecodeString(subscription.Keys.P256DH)
 	if err != nil {
 		log.Printf("decoding client public key failed: %v", err)
 		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid client public key", err.Error())
 		return sub, errors.NewResponseError(payload, http.StatusBadRequest)
 	}
 
-	decodedAuthSecret, err := base64.RawURLEncoding.DecodeString(r.Subscription.Keys.Auth)
+	decodedAuthSecret, err := base64.RawURLEncoding.DecodeString(subscription.Keys.Auth)
 	if err != nil {
 		log.Printf("decoding auth secret failed: %v", err)
 		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid auth secret", err.Error())
 		return sub, errors.NewResponseError(payload, http.StatusBadRequest)
 	}
 
-	sub = &models.PushSubscription{
-		Endpoint:       (*utils.EncryptedString)(&r.Subscription.Endpoint),
-		ExpirationTime: r.Subscription.ExpirationTime,
-		ClientId:       r.ClientId,
-		RecipientId:    r.RecipientId,
+	return &models.PushSubscription{
+		Endpoint:       (*utils.EncryptedString)(&subscription.Endpoint),
+		ExpirationTime: subscription.ExpirationTime,
 		Keys: &models.SubscriptionKeys{
 			P256DH:     (*utils.EncryptedBytes)(&decodedClientKey),
 			AuthSecret: (*utils.EncryptedBytes)(&decodedAuthSecret),
 		},
-	}
-
-	if err = sub.Validate(); err != nil {
-		return nil, err
-	}
-
-	return
+	}, nil
 }
@@ -0,0 +1,81 @@
+package request
+
+import (
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+type OldSubscriptionKeys struct {
+	Auth string `json:"auth" validate:"len=22"`
+}
+
+// OldSubscription identifies the replaced subscription either by its endpoint, e.g. from PushSubscriptionChangeEvent.oldSubscription,
+// or by its base64url-encoded endpoint hash. Its auth secret proves, that the caller holds the old subscription.
+type OldSubscription struct {
+	Endpoint string               `json:"endpoint,omitempty" validate:"required_without=Hash,omitempty,http_url"`
+	Hash     string               `json:"hash,omitempty" validate:"required_without=Endpoint,omitempty,max=64"`
+	Keys     *OldSubscriptionKeys `json:"keys" validate:"required"`
+}
+
+type SubscriptionChangeRequest struct {
+	ClientId        string                       `json:"clientId" validate:"required"`
+	OldSubscription *OldSubscription             `json:"oldSubscription" validate:"required"`
+	Subscription    *utils.RecipientSubscription `json:"subscription" validate:"required"`
+}
+
+// SubscriptionChange holds the decoded contents of a SubscriptionChangeRequest.
+type SubscriptionChange struct {
+	ClientId      string
+	OldHashes     [][]byte
+	OldAuthSecret []byte
+	Subscription  *models.PushSubscription
+}
+
+func ParseSubscriptionChangeRequest(req *http.Request) (change *SubscriptionChange, err error) {
+	r := &SubscriptionChangeRequest{}
+
+	if err = ParseBody(req, r); err != nil {
+		return
+	}
+
+	if err = utils.CustomValidateStruct(r); err != nil {
+		log.Printf("validation of subscription change request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	change = &SubscriptionChange{ClientId: r.ClientId}
+
+	if r.OldSubscription.Endpoint != "" {
+		change.OldHashes = utils.Hashes([]byte(r.OldSubscription.Endpoint))
+	} else {
+		hash, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(r.OldSubscription.Hash, "="))
+		if err != nil {
+			log.Printf("decoding old subscription hash failed: %v", err)
+			payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid old subscription hash", err.Error())
+			return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+		}
+
+		change.OldHashes = [][]byte{hash}
+	}
+
+	if change.OldAuthSecret, err = base64.RawURLEncoding.DecodeString(r.OldSubscription.Keys.Auth); err != nil {
+		log.Printf("decoding old auth secret failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "invalid old auth secret", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if change.Subscription, err = newPushSubscription(r.Subscription); err != nil {
+		return nil, err
+	}
+
+	change.Subscription.ClientId = r.ClientId
+
+	return
+}
@@ -0,0 +1,114 @@
+package request
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	webpush_test "github.com/saschazar21/go-web-push-server/test"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+func TestParseSubscriptionChangeRequest(t *testing.T) {
+	oldSubscription := &utils.RecipientSubscription{}
+	newSubscription := &utils.RecipientSubscription{}
+
+	if err := webpush_test.LoadFixture("mozilla.json", oldSubscription); err != nil {
+		t.Fatalf("failed to load subscription fixture: %v", err)
+	}
+
+	if err := webpush_test.LoadFixture("fcm.json", newSubscription); err != nil {
+		t.Fatalf("failed to load subscription fixture: %v", err)
+	}
+
+	oldHash := utils.Hash([]byte(oldSubscription.Endpoint))
+
+	type testCase struct {
+		name    string
+		payload *SubscriptionChangeRequest
+		wantErr bool
+	}
+
+	tests := []testCase{
+		{
+			name: "should parse change request with old endpoint",
+			payload: &SubscriptionChangeRequest{
+				ClientId:        "test client",
+				OldSubscription: &OldSubscription{Endpoint: oldSubscription.Endpoint, Keys: &OldSubscriptionKeys{Auth: oldSubscription.Keys.Auth}},
+				Subscription:    newSubscription,
+			},
+		},
+		{
+			name: "should parse change request with old hash",
+			payload: &SubscriptionChangeRequest{
+				ClientId:        "test client",
+				OldSubscription: &OldSubscription{Hash: base64.RawURLEncoding.EncodeToString(oldHash[:]), Keys: &OldSubscriptionKeys{Auth: oldSubscription.Keys.Auth}},
+				Subscription:    newSubscription,
+			},
+		},
+		{
+			name: "should return error on missing old endpoint and hash",
+			payload: &SubscriptionChangeRequest{
+				ClientId:        "test client",
+				OldSubscription: &OldSubscription{Keys: &OldSubscriptionKeys{Auth: oldSubscription.Keys.Auth}},
+				Subscription:    newSubscription,
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return error on missing old auth secret",
+			payload: &SubscriptionChangeRequest{
+				ClientId:        "test client",
+				OldSubscription: &OldSubscription{Endpoint: oldSubscription.Endpoint},
+				Subscription:    newSubscription,
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return error on missing new subscription",
+			payload: &SubscriptionChangeRequest{
+				ClientId:        "test client",
+				OldSubscription: &OldSubscription{Endpoint: oldSubscription.Endpoint, Keys: &OldSubscriptionKeys{Auth: oldSubscription.Keys.Auth}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := json.Marshal(tc.payload)
+
+			if err != nil {
+				t.Fatalf("failed to marshal payload: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "https:///api/v1/subscribe/change", bytes.NewReader(payload))
+			req.Header.Set("content-type", utils.APPLICATION_JSON)
+
+			change, err := ParseSubscriptionChangeRequest(req)
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, but got %v", tc.wantErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(change.OldHashes) == 0 || !bytes.Equal(change.OldHashes[0], oldHash[:]) {
+				t.Errorf("expected old hash %x, but got %x", oldHash, change.OldHashes)
+			}
+
+			if len(change.OldAuthSecret) != 16 {
+				t.Errorf("expected 16 byte auth secret, but got %d bytes", len(change.OldAuthSecret))
+			}
+
+			if change.Subscription.ClientId != tc.payload.ClientId {
+				t.Errorf("expected client ID %s, but got %s", tc.payload.ClientId, change.Subscription.ClientId)
+			}
+		})
+	}
+}
@@ -54,6 +54,18 @@ func TestParseSubscriptionReqeuest(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "should return error on private endpoint",
+			recipient: &utils.Recipient{
+				ClientId:    "test client",
+				RecipientId: "test user",
+				Subscription: &utils.RecipientSubscription{
+					Endpoint: "https://127.0.0.1/push",
+					Keys:     recipientSubscription.Keys,
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
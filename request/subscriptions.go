@@ -0,0 +1,65 @@
+package request
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+// SubscriptionsQuery holds the query parameters of GET /api/v1/subscriptions.
+type SubscriptionsQuery struct {
+	RecipientId    string `schema:"recipientId" validate:"omitempty,max=255"`
+	PushService    string `schema:"pushService" validate:"omitempty,max=32"`
+	ExpiringBefore string `schema:"expiringBefore" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedAfter   string `schema:"createdAfter" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+
+	MinConsecutiveFailures int    `schema:"minConsecutiveFailures" validate:"omitempty,min=1"`
+	Quarantined            *bool  `schema:"quarantined"`
+	Cursor                 string `schema:"cursor"`
+	Limit                  int    `schema:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+func parseTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
+
+// Filter validates the query and converts it into a subscription filter.
+func (q *SubscriptionsQuery) Filter() (filter *models.SubscriptionFilter, err error) {
+	if err = utils.CustomValidateStruct(q); err != nil {
+		log.Printf("validation of subscriptions query failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	filter = &models.SubscriptionFilter{
+		RecipientId:    q.RecipientId,
+		PushService:    q.PushService,
+		ExpiringBefore: parseTime(q.ExpiringBefore),
+		CreatedAfter:   parseTime(q.CreatedAfter),
+
+		MinConsecutiveFailures: q.MinConsecutiveFailures,
+		Quarantined:            q.Quarantined,
+		Limit:                  q.Limit,
+	}
+
+	if q.Cursor != "" {
+		if filter.Cursor, err = models.ParseSubscriptionCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
@@ -0,0 +1,42 @@
+package request
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestSubscriptionsQueryFilter(t *testing.T) {
+	cursor := (&models.SubscriptionCursor{CreatedAt: time.UnixMicro(1700000000123456).UTC(), Hash: []byte{1, 2, 3}}).String()
+
+	type test struct {
+		name    string
+		query   *SubscriptionsQuery
+		wantErr bool
+	}
+
+	tests := []test{
+		{"accepts an empty query", &SubscriptionsQuery{}, false},
+		{"accepts all filters", &SubscriptionsQuery{RecipientId: "a", PushService: "fcm", ExpiringBefore: "2030-01-01T00:00:00Z", CreatedAfter: "2024-01-01T12:00:00+02:00", MinConsecutiveFailures: 3, Cursor: cursor, Limit: 10}, false},
+		{"rejects invalid dates", &SubscriptionsQuery{CreatedAfter: "yesterday"}, true},
+		{"rejects limits above 100", &SubscriptionsQuery{Limit: 101}, true},
+		{"rejects negative failure counts", &SubscriptionsQuery{MinConsecutiveFailures: -1}, true},
+		{"rejects invalid cursors", &SubscriptionsQuery{Cursor: "invalid"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := tt.query.Filter()
+
+			assert.Equal(t, err != nil, tt.wantErr, "err = %v", err)
+
+			if err == nil && tt.query.Cursor != "" {
+				assert.DeepEqual(t, filter.Cursor.Hash, []byte{1, 2, 3})
+				assert.Equal(t, filter.Cursor.CreatedAt.UnixMicro(), int64(1700000000123456))
+				assert.Equal(t, filter.CreatedAfter.UTC(), time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+			}
+		})
+	}
+}
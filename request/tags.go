@@ -0,0 +1,56 @@
+package request
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+type TagsRequest struct {
+	Tags []string `json:"tags" validate:"max=32,dive,tag"`
+}
+
+type TagsUpdateRequest struct {
+	Add    []string `json:"add,omitempty" validate:"max=32,dive,tag"`
+	Remove []string `json:"remove,omitempty" validate:"max=32,dive,tag"`
+}
+
+func parseTagsBody(req *http.Request, iface any, method string) (err error) {
+	if err = ParseBody(req, iface, method); err != nil {
+		return
+	}
+
+	if err = utils.CustomValidateStruct(iface); err != nil {
+		log.Printf("validation of tags request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func ParseTagsRequest(req *http.Request) (r *TagsRequest, err error) {
+	r = &TagsRequest{}
+
+	if err = parseTagsBody(req, r, http.MethodPut); err != nil {
+		return nil, err
+	}
+
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+
+	return
+}
+
+func ParseTagsUpdateRequest(req *http.Request) (r *TagsUpdateRequest, err error) {
+	r = &TagsUpdateRequest{}
+
+	if err = parseTagsBody(req, r, http.MethodPatch); err != nil {
+		return nil, err
+	}
+
+	return
+}
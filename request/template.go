@@ -0,0 +1,122 @@
+package request
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+type TemplateRequest struct {
+	TemplateId string         `json:"id" validate:"required,max=255"`
+	Title      string         `json:"title" validate:"required"`
+	Body       string         `json:"body,omitempty"`
+	Icon       string         `json:"icon,omitempty"`
+	Tag        string         `json:"tag,omitempty"`
+	URL        string         `json:"url,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+type TemplatePushRequest struct {
+	TemplateId string                    `json:"template" validate:"required"`
+	Version    int                       `json:"version,omitempty" validate:"gte=0"`
+	Variables  map[string]any            `json:"variables,omitempty"`
+	Default    string                    `json:"default,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Locales    map[string]map[string]any `json:"locales,omitempty" validate:"omitempty,dive,keys,bcp47_language_tag,endkeys"`
+}
+
+// VariablesFor returns the template variables for the given locale, overriding the shared variables with the localized ones.
+func (r *TemplatePushRequest) VariablesFor(locale string) (variables map[string]any) {
+	variables = make(map[string]any, len(r.Variables))
+
+	for key, value := range r.Variables {
+		variables[key] = value
+	}
+
+	for key, value := range r.Locales[locale] {
+		variables[key] = value
+	}
+
+	return
+}
+
+func (r *TemplatePushRequest) Validate() (err error) {
+	if err = utils.CustomValidateStruct(r); err != nil {
+		log.Printf("validation of template push request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func ParseTemplatePushRequest(body []byte) (r *TemplatePushRequest, err error) {
+	r = &TemplatePushRequest{}
+
+	if err = json.Unmarshal(body, r); err != nil {
+		log.Println(err)
+
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "failed to parse JSON body")
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+
+	if r.Default == "" {
+		r.Default = utils.GetDefaultLocale()
+	}
+
+	return
+}
+
+func ParseTemplatePreviewRequest(req *http.Request, templateId string) (r *TemplatePushRequest, err error) {
+	r = &TemplatePushRequest{}
+
+	if err = ParseBody(req, r); err != nil {
+		return nil, err
+	}
+
+	r.TemplateId = templateId
+
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func ParseTemplateRequest(req *http.Request, clientId string) (tpl *models.NotificationTemplate, err error) {
+	r := &TemplateRequest{}
+
+	if err = ParseBody(req, r); err != nil {
+		return nil, err
+	}
+
+	if err = utils.CustomValidateStruct(r); err != nil {
+		log.Printf("validation of template request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "validation failed", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	tpl = &models.NotificationTemplate{
+		ClientId:   clientId,
+		TemplateId: r.TemplateId,
+		Title:      r.Title,
+		Body:       r.Body,
+		Icon:       r.Icon,
+		Tag:        r.Tag,
+		URL:        r.URL,
+		Data:       r.Data,
+	}
+
+	if err = tpl.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
@@ -29,7 +29,7 @@ func (s *WithSalt) String() string {
 }
 
 type WithWebPushParams struct {
-	Topic   string `json:"topic,omitempty" schema:"topic"`
+	Topic   string `json:"topic,omitempty" schema:"topic" validate:"omitempty,topic"` // see https://datatracker.ietf.org/doc/html/rfc8030#section-5.4
 	TTL     int64  `json:"ttl" schema:"ttl" validate:"gte=0"`
 	Urgency string `json:"urgency,omitempty" schema:"urgency" validate:"omitempty,oneof=very-low low normal high"` // see https://datatracker.ietf.org/doc/html/rfc8030#section-5.3
 }
@@ -37,6 +37,10 @@ type WithWebPushParams struct {
 type WebPushDetails struct {
 	ClientId    string `json:"client" schema:"client" validate:"required"`
 	RecipientId string `json:"id,omitempty" schema:"id"`
+	Mode        string `json:"mode,omitempty" schema:"mode" validate:"omitempty,oneof=raw declarative localized template"`
+	Tags        string `json:"tags,omitempty" schema:"tags"`
+	Target      string `json:"target,omitempty" schema:"target" validate:"omitempty,oneof=recipients"`
+	DryRun      bool   `json:"dryRun,omitempty" schema:"dryRun"`
 
 	*WithWebPushParams
 }
@@ -9,6 +9,7 @@ import (
 	"net/url"
 
 	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/provider"
 	"github.com/saschazar21/go-web-push-server/utils"
 	"github.com/saschazar21/go-web-push-server/vapid"
 )
@@ -51,6 +52,14 @@ func (r *WebPushRequest) Send() (res *http.Response, err error) {
 		return res, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError)
 	}
 
+	policy := provider.GetPolicy()
+
+	if err = policy.Validate(r.Endpoint); err != nil {
+		log.Printf("%s: %v\n", r.String(), err)
+
+		return res, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError)
+	}
+
 	var req *http.Request
 
 	if req, err = http.NewRequest(http.MethodPost, r.Endpoint, bytes.NewBuffer(r.Payload)); err != nil {
@@ -69,22 +78,16 @@ func (r *WebPushRequest) Send() (res *http.Response, err error) {
 		http.CanonicalHeaderKey("Authorization"):    {fmt.Sprintf("vapid t=%s,k=%s", jwt, key)},
 		http.CanonicalHeaderKey("Content-Encoding"): {"aes128gcm"},
 		http.CanonicalHeaderKey("Content-Type"):     {"application/octet-stream"},
-		http.CanonicalHeaderKey("TTL"):              {fmt.Sprintf("%d", r.TTL)},
-		// Microsoft Edge header values
-		"X-WNS-Type":         {"wns/raw"},
-		"X-WNS-Cache-Policy": {"cache"},
-	}
-
-	if r.Topic != "" {
-		req.Header.Add("Topic", r.Topic)
 	}
 
-	if r.Urgency != "" {
-		req.Header.Add("Urgency", r.Urgency)
-	}
+	// only the headers and limits of the endpoint's push service apply, e.g. the X-WNS-* headers for Microsoft Edge
+	provider.ForEndpoint(r.Endpoint).Apply(req.Header, r.TTL, r.Topic, r.Urgency)
 
+	// the policy is enforced again at dial time, as the endpoint's host might resolve to a private IP
 	client := &http.Client{
+		CheckRedirect: policy.CheckRedirect,
 		Transport: &http.Transport{
+			DialContext:  policy.DialContext,
 			TLSNextProto: make(map[string]func(authority string, c *tls.Conn) http.RoundTripper),
 		},
 	}
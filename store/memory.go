@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/provider"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+// memoryStore keeps the subscriptions within the current process, keyed by the hash of their endpoint.
+// It returns subscriptions in the same shape as the Postgres store, i.e. with base64url-encoded hashes, along with their tags.
+type memoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]*models.PushSubscription
+}
+
+func NewMemoryStore() SubscriptionStore {
+	return &memoryStore{
+		subscriptions: map[string]*models.PushSubscription{},
+	}
+}
+
+func (s *memoryStore) Save(ctx context.Context, sub *models.PushSubscription) (err error) {
+	if sub.Keys == nil {
+		log.Printf("%s has no keys, aborting save", sub)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Subscription keys are required")
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	if err = sub.Validate(); err != nil {
+		return
+	}
+
+	if sub.Endpoint == nil {
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid subscription contents", "endpoint is required")
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	endpoint := string(*sub.Endpoint)
+	hash := utils.Hash([]byte(endpoint))
+	key := string(hash[:])
+	now := time.Now().UTC()
+
+	// mirrors PushSubscription.BeforeAppendModel
+	endpointHash := utils.HashedString(endpoint)
+	sub.Hash = &endpointHash
+	sub.PushService = provider.ForEndpoint(endpoint).Name
+	sub.Keys.PushSubscriptionHash = sub.Hash
+
+	if err = sub.Keys.Validate(); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := cloneSubscription(sub)
+	stored.Hash = encodeHash(hash[:])
+	stored.UpdatedAt = now
+
+	if stored.Locale == "" {
+		stored.Locale = utils.DEFAULT_LOCALE
+	}
+
+	if existing, ok := s.subscriptions[key]; ok {
+		// the upsert only updates the subscription, the delivery metadata and quarantine are retained
+		stored.CreatedAt = existing.CreatedAt
+		stored.LastSuccessAt = existing.LastSuccessAt
+		stored.LastFailureAt = existing.LastFailureAt
+		stored.LastStatusCode = existing.LastStatusCode
+		stored.ConsecutiveFailures = existing.ConsecutiveFailures
+		stored.QuarantinedAt = existing.QuarantinedAt
+
+		// same as the Postgres store, the tags are only replaced, when given
+		if stored.Tags == nil {
+			stored.Tags = existing.Tags
+		}
+	} else if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now
+	}
+
+	p256dhHash := utils.Hash(*sub.Keys.P256DH)
+	authSecretHash := utils.Hash(*sub.Keys.AuthSecret)
+
+	stored.Keys.Hash = encodeHash(p256dhHash[:])
+	stored.Keys.AuthSecretHash = encodeHash(authSecretHash[:])
+	stored.Keys.PushSubscriptionHash = stored.Hash
+
+	s.subscriptions[key] = stored
+
+	return nil
+}
+
+func (s *memoryStore) GetByHash(ctx context.Context, hash string) (*models.PushSubscription, error) {
+	decoded, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		log.Printf("decoding subscription hash failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid subscription hash", err.Error())
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[string(decoded)]
+	if !ok || !isActive(sub) {
+		log.Printf("fetching subscription by hash failed: %v", sql.ErrNoRows)
+		payload := errors.NewErrorResponse(http.StatusInternalServerError, "Failed to fetch subscription", sql.ErrNoRows.Error())
+		return nil, errors.NewResponseError(payload, http.StatusInternalServerError)
+	}
+
+	return cloneSubscription(sub), nil
+}
+
+func (s *memoryStore) GetByClientId(ctx context.Context, clientId string) ([]*models.PushSubscription, error) {
+	return s.filter(func(sub *models.PushSubscription) bool {
+		return sub.ClientId == clientId
+	}), nil
+}
+
+func (s *memoryStore) GetByClientIdAndRecipientId(ctx context.Context, clientId, recipientId string) ([]*models.PushSubscription, error) {
+	return s.filter(func(sub *models.PushSubscription) bool {
+		return sub.ClientId == clientId && sub.RecipientId == recipientId
+	}), nil
+}
+
+func (s *memoryStore) GetByClientIdAndRecipientIds(ctx context.Context, clientId string, recipientIds []string) ([]*models.PushSubscription, error) {
+	ids := make(map[string]bool, len(recipientIds))
+	for _, id := range recipientIds {
+		ids[id] = true
+	}
+
+	return s.filter(func(sub *models.PushSubscription) bool {
+		return sub.ClientId == clientId && ids[sub.RecipientId]
+	}), nil
+}
+
+func (s *memoryStore) GetByTags(ctx context.Context, clientId, recipientId string, expr models.TagExpression) ([]*models.PushSubscription, error) {
+	return s.filter(func(sub *models.PushSubscription) bool {
+		return sub.ClientId == clientId && (recipientId == "" || sub.RecipientId == recipientId) && expr.Matches(sub.Tags)
+	}), nil
+}
+
+func (s *memoryStore) HasExistingByClientId(ctx context.Context, clientId string) (bool, error) {
+	subscriptions, _ := s.GetByClientId(ctx, clientId)
+
+	return len(subscriptions) > 0, nil
+}
+
+func (s *memoryStore) DeleteByEndpoint(ctx context.Context, endpoint string) error {
+	hash := utils.Hash([]byte(endpoint))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscriptions, string(hash[:]))
+
+	return nil
+}
+
+func (s *memoryStore) DeleteByClientId(ctx context.Context, clientId string) error {
+	return s.delete(func(sub *models.PushSubscription) bool {
+		return sub.ClientId == clientId
+	})
+}
+
+func (s *memoryStore) DeleteByClientIdAndRecipientId(ctx context.Context, clientId, recipientId string) error {
+	return s.delete(func(sub *models.PushSubscription) bool {
+		return sub.ClientId == clientId && sub.RecipientId == recipientId
+	})
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// filter returns copies of the active subscriptions matching the predicate, ordered by their creation time.
+func (s *memoryStore) filter(match func(sub *models.PushSubscription) bool) []*models.PushSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := make([]*models.PushSubscription, 0)
+
+	for _, sub := range s.subscriptions {
+		if isActive(sub) && match(sub) {
+			subscriptions = append(subscriptions, cloneSubscription(sub))
+		}
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].Hash.String() < subscriptions[j].Hash.String()
+		}
+
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+
+	return subscriptions
+}
+
+func (s *memoryStore) delete(match func(sub *models.PushSubscription) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, sub := range s.subscriptions {
+		if match(sub) {
+			delete(s.subscriptions, key)
+		}
+	}
+
+	return nil
+}
+
+// isActive reports whether the subscription is neither expired nor quarantined.
+func isActive(sub *models.PushSubscription) bool {
+	if sub.QuarantinedAt != nil {
+		return false
+	}
+
+	return sub.ExpirationTime == nil || time.Time(*sub.ExpirationTime).After(time.Now())
+}
+
+func encodeHash(hash []byte) *utils.HashedString {
+	encoded := utils.HashedString(base64.RawURLEncoding.EncodeToString(hash))
+	return &encoded
+}
+
+// cloneSubscription copies the subscription and its keys, so callers can't modify the stored values.
+func cloneSubscription(sub *models.PushSubscription) *models.PushSubscription {
+	clone := *sub
+
+	if sub.Endpoint != nil {
+		endpoint := *sub.Endpoint
+		clone.Endpoint = &endpoint
+	}
+
+	if sub.Keys != nil {
+		keys := *sub.Keys
+
+		if sub.Keys.P256DH != nil {
+			p256dh := append(utils.EncryptedBytes{}, *sub.Keys.P256DH...)
+			keys.P256DH = &p256dh
+		}
+
+		if sub.Keys.AuthSecret != nil {
+			authSecret := append(utils.EncryptedBytes{}, *sub.Keys.AuthSecret...)
+			keys.AuthSecret = &authSecret
+		}
+
+		clone.Keys = &keys
+	}
+
+	if sub.Tags != nil {
+		clone.Tags = append([]string{}, sub.Tags...)
+	}
+
+	return &clone
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/extra/bundebug"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sqlite.sql
+var sqliteSchema string
+
+// sqlStore delegates to the functions of the models package, which run on both Postgres and SQLite.
+type sqlStore struct {
+	db bun.IDB
+	// conn is only closed, when the store opened it
+	conn *bun.DB
+}
+
+func (s *sqlStore) Save(ctx context.Context, sub *models.PushSubscription) error {
+	return sub.Save(ctx, s.db)
+}
+
+func (s *sqlStore) GetByHash(ctx context.Context, hash string) (*models.PushSubscription, error) {
+	return models.GetSubscriptionByHash(ctx, s.db, hash)
+}
+
+func (s *sqlStore) GetByClientId(ctx context.Context, clientId string) ([]*models.PushSubscription, error) {
+	return models.GetSubscriptionsByClientId(ctx, s.db, clientId)
+}
+
+func (s *sqlStore) GetByClientIdAndRecipientId(ctx context.Context, clientId, recipientId string) ([]*models.PushSubscription, error) {
+	return models.GetSubscriptionsByClientIdAndRecipientId(ctx, s.db, clientId, recipientId)
+}
+
+func (s *sqlStore) GetByClientIdAndRecipientIds(ctx context.Context, clientId string, recipientIds []string) ([]*models.PushSubscription, error) {
+	return models.GetSubscriptionsByClientIdAndRecipientIds(ctx, s.db, clientId, recipientIds)
+}
+
+func (s *sqlStore) GetByTags(ctx context.Context, clientId, recipientId string, expr models.TagExpression) ([]*models.PushSubscription, error) {
+	return models.GetSubscriptionsByTags(ctx, s.db, clientId, recipientId, expr)
+}
+
+func (s *sqlStore) HasExistingByClientId(ctx context.Context, clientId string) (bool, error) {
+	return models.HasExistingSubscriptionsByClientId(ctx, s.db, clientId)
+}
+
+func (s *sqlStore) DeleteByEndpoint(ctx context.Context, endpoint string) error {
+	return models.DeleteSubscriptionByEndpoint(ctx, s.db, endpoint)
+}
+
+func (s *sqlStore) DeleteByClientId(ctx context.Context, clientId string) error {
+	return models.DeleteSubscriptionsByClientId(ctx, s.db, clientId)
+}
+
+func (s *sqlStore) DeleteByClientIdAndRecipientId(ctx context.Context, clientId, recipientId string) error {
+	return models.DeleteSubscriptionsByClientIdAndRecipientId(ctx, s.db, clientId, recipientId)
+}
+
+func (s *sqlStore) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.Close()
+}
+
+// NewPostgresStore wraps an existing Postgres connection or transaction, which is left open by Close.
+func NewPostgresStore(db bun.IDB) SubscriptionStore {
+	return &sqlStore{db: db}
+}
+
+// OpenPostgresStore connects to the database configured by the POSTGRES_CONNECTION_STRING env.
+func OpenPostgresStore() (SubscriptionStore, error) {
+	conn, err := db.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlStore{db: conn, conn: conn}, nil
+}
+
+// OpenSQLiteStore opens the SQLite database at the given path, e.g. ":memory:" for tests, and creates its schema, if missing.
+func OpenSQLiteStore(path string) (SubscriptionStore, error) {
+	if err := utils.ValidateHashSecret(); err != nil {
+		return nil, err
+	}
+
+	sqlDb, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	// every connection to ":memory:" would open a separate database
+	sqlDb.SetMaxOpenConns(1)
+
+	conn := bun.NewDB(sqlDb, sqlitedialect.New())
+
+	conn.AddQueryHook(bundebug.NewQueryHook(
+		bundebug.WithEnabled(false),
+		bundebug.FromEnv("DEBUG"),
+	))
+
+	if _, err = conn.Exec(sqliteSchema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+
+	return &sqlStore{db: conn, conn: conn}, nil
+}
@@ -0,0 +1,44 @@
+-- The SQLite schema of the subscription store, a subset of the Postgres schema created by db/migrations, applied on every start
+CREATE TABLE IF NOT EXISTS webpush_subscriptions (
+  endpoint_hash BLOB PRIMARY KEY,
+  endpoint BLOB NOT NULL,
+  expiration_time TIMESTAMP,
+  client_id VARCHAR(255) NOT NULL,
+  recipient_id VARCHAR(255) NOT NULL,
+  locale VARCHAR(35) NOT NULL DEFAULT 'en',
+  push_service VARCHAR(32) NOT NULL DEFAULT 'unknown',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_success_at TIMESTAMP,
+  last_failure_at TIMESTAMP,
+  last_status_code INTEGER,
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  quarantined_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_recipient_id ON webpush_subscriptions(recipient_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_client_id ON webpush_subscriptions(client_id);
+
+CREATE TABLE IF NOT EXISTS webpush_keys (
+  p256dh_hash BLOB PRIMARY KEY,
+  p256dh BLOB NOT NULL,
+  auth_secret_hash BLOB NOT NULL UNIQUE,
+  auth_secret BLOB NOT NULL,
+  subscription_hash BLOB NOT NULL UNIQUE REFERENCES webpush_subscriptions(endpoint_hash) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webpush_subscription_tags (
+  subscription_hash BLOB NOT NULL REFERENCES webpush_subscriptions(endpoint_hash) ON DELETE CASCADE,
+  tag VARCHAR(255) NOT NULL,
+  PRIMARY KEY (subscription_hash, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag ON webpush_subscription_tags(tag);
+
+CREATE TABLE IF NOT EXISTS webpush_delivery_failures (
+  subscription_hash BLOB NOT NULL REFERENCES webpush_subscriptions(endpoint_hash) ON DELETE CASCADE,
+  status_code INTEGER NOT NULL,
+  failed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_failures_subscription_hash ON webpush_delivery_failures(subscription_hash, failed_at);
@@ -0,0 +1,86 @@
+// Package store abstracts the storage of push subscriptions, so small deployments and tests may run without Postgres.
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/saschazar21/go-web-push-server/db"
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/uptrace/bun"
+)
+
+const (
+	STORE_MEMORY   = "memory"
+	STORE_POSTGRES = "postgres"
+	STORE_SQLITE   = "sqlite"
+)
+
+const DEFAULT_SQLITE_PATH = "webpush.db"
+
+// SubscriptionStore saves, looks up and deletes push subscriptions together with their keys and tags.
+// Lookups exclude expired and quarantined subscriptions, just like the functions of the models package.
+type SubscriptionStore interface {
+	Save(ctx context.Context, sub *models.PushSubscription) error
+
+	GetByHash(ctx context.Context, hash string) (*models.PushSubscription, error)
+	GetByClientId(ctx context.Context, clientId string) ([]*models.PushSubscription, error)
+	GetByClientIdAndRecipientId(ctx context.Context, clientId, recipientId string) ([]*models.PushSubscription, error)
+	GetByClientIdAndRecipientIds(ctx context.Context, clientId string, recipientIds []string) ([]*models.PushSubscription, error)
+	// GetByTags returns the subscriptions of a client matching the tag expression, optionally narrowed down to a single recipient.
+	GetByTags(ctx context.Context, clientId, recipientId string, expr models.TagExpression) ([]*models.PushSubscription, error)
+	HasExistingByClientId(ctx context.Context, clientId string) (bool, error)
+
+	DeleteByEndpoint(ctx context.Context, endpoint string) error
+	DeleteByClientId(ctx context.Context, clientId string) error
+	DeleteByClientIdAndRecipientId(ctx context.Context, clientId, recipientId string) error
+
+	// Close releases the underlying database connection, if any.
+	Close() error
+}
+
+var defaultMemoryStore = NewMemoryStore()
+
+// Open returns the store selected by the SUBSCRIPTION_STORE env, defaulting to the Postgres store.
+// The in-memory store is shared within the current process, which only suits single nodes and tests.
+func Open() (SubscriptionStore, error) {
+	switch store := os.Getenv(utils.SUBSCRIPTION_STORE_ENV); store {
+	case "", STORE_POSTGRES:
+		return OpenPostgresStore()
+	case STORE_SQLITE:
+		path := os.Getenv(utils.SQLITE_PATH_ENV)
+		if path == "" {
+			path = DEFAULT_SQLITE_PATH
+		}
+
+		return OpenSQLiteStore(path)
+	case STORE_MEMORY:
+		return defaultMemoryStore, nil
+	default:
+		log.Printf("unknown %s env %q, falling back to %s\n", utils.SUBSCRIPTION_STORE_ENV, store, STORE_POSTGRES)
+		return OpenPostgresStore()
+	}
+}
+
+// UsesPostgres reports whether the SUBSCRIPTION_STORE env selects the Postgres store, which is the default.
+func UsesPostgres() bool {
+	switch os.Getenv(utils.SUBSCRIPTION_STORE_ENV) {
+	case STORE_SQLITE, STORE_MEMORY:
+		return false
+	}
+
+	return true
+}
+
+// ConnectPostgres connects to Postgres for the endpoints and commands, which don't use a SubscriptionStore yet.
+// It refuses to, when SUBSCRIPTION_STORE selects another store, as the subscriptions would be split between both databases.
+func ConnectPostgres() (*bun.DB, error) {
+	if !UsesPostgres() {
+		return nil, fmt.Errorf("%s=%s is only supported by the subscribe, unsubscribe and push endpoints, the other endpoints and commands require %s", utils.SUBSCRIPTION_STORE_ENV, os.Getenv(utils.SUBSCRIPTION_STORE_ENV), STORE_POSTGRES)
+	}
+
+	return db.Connect()
+}
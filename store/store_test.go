@@ -0,0 +1,228 @@
+package store
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"gotest.tools/v3/assert"
+)
+
+const TEST_CLIENT_ID = "test-client"
+
+func newTestSubscription(t *testing.T, recipientId, endpoint string) *models.PushSubscription {
+	privateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+
+	p256dh := privateKey.PublicKey().Bytes()
+	authSecret := make([]byte, 16)
+	_, err = rand.Read(authSecret)
+	assert.NilError(t, err)
+
+	return &models.PushSubscription{
+		Endpoint:    (*utils.EncryptedString)(&endpoint),
+		ClientId:    TEST_CLIENT_ID,
+		RecipientId: recipientId,
+		Keys: &models.SubscriptionKeys{
+			P256DH:     (*utils.EncryptedBytes)(&p256dh),
+			AuthSecret: (*utils.EncryptedBytes)(&authSecret),
+		},
+	}
+}
+
+// testSubscriptionStore runs the same assertions against every implementation of SubscriptionStore.
+func testSubscriptionStore(t *testing.T, s SubscriptionStore) {
+	ctx := context.Background()
+
+	for i := range 3 {
+		recipientId := fmt.Sprintf("recipient-%d", i%2)
+		endpoint := fmt.Sprintf("https://fcm.googleapis.com/fcm/send/store-%d", i)
+
+		assert.NilError(t, s.Save(ctx, newTestSubscription(t, recipientId, endpoint)))
+	}
+
+	t.Run("refuses subscriptions without keys", func(t *testing.T) {
+		sub := newTestSubscription(t, "recipient-0", "https://fcm.googleapis.com/fcm/send/no-keys")
+		sub.Keys = nil
+
+		assert.Assert(t, s.Save(ctx, sub) != nil)
+	})
+
+	t.Run("upserts an existing subscription", func(t *testing.T) {
+		sub := newTestSubscription(t, "recipient-0", "https://fcm.googleapis.com/fcm/send/store-0")
+		sub.Locale = "de"
+
+		assert.NilError(t, s.Save(ctx, sub))
+
+		subscriptions, err := s.GetByClientId(ctx, TEST_CLIENT_ID)
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 3)
+	})
+
+	t.Run("looks up subscriptions by recipient", func(t *testing.T) {
+		subscriptions, err := s.GetByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-0")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 2)
+
+		for _, sub := range subscriptions {
+			assert.Equal(t, sub.RecipientId, "recipient-0")
+			assert.Equal(t, sub.PushService, "fcm")
+			assert.Assert(t, sub.Keys != nil && len(*sub.Keys.P256DH) == 65)
+			assert.Assert(t, len(*sub.Keys.AuthSecret) == 16)
+		}
+
+		subscriptions, err = s.GetByClientIdAndRecipientIds(ctx, TEST_CLIENT_ID, []string{"recipient-0", "recipient-1"})
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 3)
+
+		subscriptions, err = s.GetByClientIdAndRecipientId(ctx, "unknown", "recipient-0")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 0)
+	})
+
+	t.Run("looks up a subscription by hash", func(t *testing.T) {
+		endpoint := "https://fcm.googleapis.com/fcm/send/store-1"
+		hash := utils.Hash([]byte(endpoint))
+
+		sub, err := s.GetByHash(ctx, base64.StdEncoding.EncodeToString(hash[:]))
+		assert.NilError(t, err)
+		assert.Equal(t, string(*sub.Endpoint), endpoint)
+		assert.Equal(t, sub.Hash.String(), base64.RawURLEncoding.EncodeToString(hash[:]))
+
+		unknown := utils.Hash([]byte("https://fcm.googleapis.com/fcm/send/unknown"))
+
+		_, err = s.GetByHash(ctx, base64.StdEncoding.EncodeToString(unknown[:]))
+		assert.Assert(t, err != nil)
+	})
+
+	t.Run("looks up subscriptions by tags", func(t *testing.T) {
+		beta := newTestSubscription(t, "recipient-3", "https://fcm.googleapis.com/fcm/send/tagged-0")
+		beta.Tags = []string{"beta", "plan:pro"}
+		assert.NilError(t, s.Save(ctx, beta))
+
+		churned := newTestSubscription(t, "recipient-4", "https://fcm.googleapis.com/fcm/send/tagged-1")
+		churned.Tags = []string{"beta", "churned"}
+		assert.NilError(t, s.Save(ctx, churned))
+
+		expr, err := models.ParseTagExpression("beta AND NOT churned")
+		assert.NilError(t, err)
+
+		subscriptions, err := s.GetByTags(ctx, TEST_CLIENT_ID, "", expr)
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 1)
+		assert.Equal(t, subscriptions[0].RecipientId, "recipient-3")
+
+		// saving without tags retains the stored tags
+		beta.Tags = nil
+		assert.NilError(t, s.Save(ctx, beta))
+
+		expr, err = models.ParseTagExpression("plan:pro")
+		assert.NilError(t, err)
+
+		subscriptions, err = s.GetByTags(ctx, TEST_CLIENT_ID, "recipient-3", expr)
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 1)
+
+		subscriptions, err = s.GetByTags(ctx, TEST_CLIENT_ID, "recipient-4", expr)
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 0)
+
+		assert.NilError(t, s.DeleteByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-3"))
+		assert.NilError(t, s.DeleteByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-4"))
+	})
+
+	t.Run("excludes expired subscriptions", func(t *testing.T) {
+		sub := newTestSubscription(t, "recipient-2", "https://fcm.googleapis.com/fcm/send/expiring")
+		expiration := utils.EpochMillis(time.Now().Add(time.Second))
+		sub.ExpirationTime = &expiration
+
+		assert.NilError(t, s.Save(ctx, sub))
+
+		subscriptions, err := s.GetByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-2")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 1)
+
+		time.Sleep(1100 * time.Millisecond)
+
+		subscriptions, err = s.GetByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-2")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 0)
+	})
+
+	t.Run("deletes subscriptions", func(t *testing.T) {
+		assert.NilError(t, s.DeleteByEndpoint(ctx, "https://fcm.googleapis.com/fcm/send/store-2"))
+
+		subscriptions, err := s.GetByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-0")
+		assert.NilError(t, err)
+		assert.Equal(t, len(subscriptions), 1)
+
+		assert.NilError(t, s.DeleteByClientIdAndRecipientId(ctx, TEST_CLIENT_ID, "recipient-0"))
+
+		exists, err := s.HasExistingByClientId(ctx, TEST_CLIENT_ID)
+		assert.NilError(t, err)
+		assert.Assert(t, exists)
+
+		assert.NilError(t, s.DeleteByClientId(ctx, TEST_CLIENT_ID))
+
+		exists, err = s.HasExistingByClientId(ctx, TEST_CLIENT_ID)
+		assert.NilError(t, err)
+		assert.Assert(t, !exists)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	testSubscriptionStore(t, NewMemoryStore())
+}
+
+func TestSQLiteStore(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+
+	s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "webpush.db"))
+	assert.NilError(t, err)
+
+	defer s.Close()
+
+	testSubscriptionStore(t, s)
+}
+
+func TestOpen(t *testing.T) {
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(utils.SUBSCRIPTION_STORE_ENV, STORE_MEMORY)
+
+	s, err := Open()
+	assert.NilError(t, err)
+	assert.Equal(t, s, defaultMemoryStore)
+
+	t.Setenv(utils.SUBSCRIPTION_STORE_ENV, STORE_SQLITE)
+	t.Setenv(utils.SQLITE_PATH_ENV, filepath.Join(t.TempDir(), "webpush.db"))
+
+	s, err = Open()
+	assert.NilError(t, err)
+	assert.NilError(t, s.Close())
+
+	t.Setenv(utils.SUBSCRIPTION_STORE_ENV, STORE_POSTGRES)
+	t.Setenv(utils.POSTGRES_CONNECTION_STRING_ENV, "")
+
+	_, err = Open()
+	assert.Assert(t, err != nil)
+}
+
+func TestConnectPostgres(t *testing.T) {
+	for _, s := range []string{STORE_MEMORY, STORE_SQLITE} {
+		t.Setenv(utils.SUBSCRIPTION_STORE_ENV, s)
+
+		_, err := ConnectPostgres()
+		assert.ErrorContains(t, err, "require postgres")
+	}
+}
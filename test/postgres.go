@@ -2,12 +2,11 @@ package webpush_test
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"os"
 	"testing"
 
 	_ "github.com/lib/pq"
+	"github.com/saschazar21/go-web-push-server/db"
 	"github.com/saschazar21/go-web-push-server/utils"
 
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
@@ -20,19 +19,10 @@ const (
 	DB_USER     = "postgres"
 	DB_PASSWORD = "postgres"
 
-	DB_LOG    = "database system is ready to accept connections"
-	DB_SCHEMA = "schema.sql"
-
-	CWD = "../"
+	DB_LOG = "database system is ready to accept connections"
 )
 
 func CreateContainer(ctx context.Context, t *testing.T) (container *postgres.PostgresContainer, err error) {
-	cwd := os.Getenv("CWD")
-
-	if cwd == "" {
-		cwd = CWD
-	}
-
 	var c *postgres.PostgresContainer
 
 	if c, err = postgres.Run(
@@ -41,16 +31,11 @@ func CreateContainer(ctx context.Context, t *testing.T) (container *postgres.Pos
 		postgres.WithDatabase(DB_NAME),
 		postgres.WithUsername(DB_USER),
 		postgres.WithPassword(DB_PASSWORD),
-		postgres.WithInitScripts(fmt.Sprintf("%v%v", cwd, DB_SCHEMA)),
 		postgres.BasicWaitStrategies(),
 	); err != nil {
 		return
 	}
 
-	if err = c.Snapshot(ctx, postgres.WithSnapshotName("test-postgres-initial")); err != nil {
-		log.Fatalf("failed to create snapshot: %v", err)
-	}
-
 	dsn, err := c.ConnectionString(ctx, "sslmode=disable")
 
 	if err != nil {
@@ -58,9 +43,27 @@ func CreateContainer(ctx context.Context, t *testing.T) (container *postgres.Pos
 	}
 
 	t.Setenv(utils.MASTER_KEY_ENV, "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI=")
+	t.Setenv(utils.HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
 	t.Setenv(utils.POSTGRES_CONNECTION_STRING_ENV, dsn)
 	t.Setenv("DEBUG", "2")
 
+	conn, err := db.Open()
+
+	if err != nil {
+		log.Fatalf("failed to connect to DB: %v", err)
+	}
+
+	if _, err = db.Migrate(ctx, conn); err != nil {
+		log.Fatalf("failed to migrate DB: %v", err)
+	}
+
+	// the snapshot copies the database, which requires all connections to be closed
+	conn.Close()
+
+	if err = c.Snapshot(ctx, postgres.WithSnapshotName("test-postgres-initial")); err != nil {
+		log.Fatalf("failed to create snapshot: %v", err)
+	}
+
 	container = c
 
 	return
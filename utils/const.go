@@ -1,13 +1,40 @@
 package utils
 
 const (
-	MASTER_KEY_ENV      = "MASTER_KEY"
-	HMAC_SECRET_KEY_ENV = "HMAC_SECRET_KEY"
+	MASTER_KEY_ENV                = "MASTER_KEY"
+	MASTER_KEY_FILE_ENV           = "MASTER_KEY_FILE"
+	PREVIOUS_MASTER_KEYS_ENV      = "PREVIOUS_MASTER_KEYS"
+	ENCRYPTION_MODE_ENV           = "ENCRYPTION_MODE"
+	HMAC_SECRET_KEY_ENV           = "HMAC_SECRET_KEY"
+	PREVIOUS_HMAC_SECRET_KEYS_ENV = "PREVIOUS_HMAC_SECRET_KEYS"
+	HMAC_ALLOW_INSECURE_ENV       = "HMAC_ALLOW_INSECURE"
 
 	POSTGRES_CONNECTION_STRING_ENV = "POSTGRES_CONNECTION_STRING"
+	SUBSCRIPTION_STORE_ENV         = "SUBSCRIPTION_STORE"
+	SQLITE_PATH_ENV                = "SQLITE_PATH"
+	MIGRATE_ON_START_ENV           = "MIGRATE_ON_START"
+	SKIP_SCHEMA_CHECK_ENV          = "SKIP_SCHEMA_CHECK"
+
+	DEFAULT_LOCALE_ENV = "DEFAULT_LOCALE"
+
+	ENDPOINT_ALLOW_INSECURE_ENV              = "ENDPOINT_ALLOW_INSECURE"
+	ENDPOINT_ALLOW_PRIVATE_IPS_ENV           = "ENDPOINT_ALLOW_PRIVATE_IPS"
+	ENDPOINT_ALLOW_UNKNOWN_PUSH_SERVICES_ENV = "ENDPOINT_ALLOW_UNKNOWN_PUSH_SERVICES"
+
+	IDEMPOTENCY_WINDOW_ENV       = "IDEMPOTENCY_WINDOW"
+	IDEMPOTENCY_LOCK_TIMEOUT_ENV = "IDEMPOTENCY_LOCK_TIMEOUT"
+
+	RATE_LIMIT_ENV       = "RATE_LIMIT"
+	RATE_LIMIT_STORE_ENV = "RATE_LIMIT_STORE"
+	QUOTA_DAILY_ENV      = "QUOTA_DAILY"
+	QUOTA_MONTHLY_ENV    = "QUOTA_MONTHLY"
 
 	SKIP_PADDING_ENV = "SKIP_PADDING"
 
+	SWEEP_GRACE_PERIOD_ENV = "SWEEP_GRACE_PERIOD"
+
+	PRUNING_POLICY_ENV = "PRUNING_POLICY"
+
 	VAPID_EXPIRY_DURATION_ENV = "VAPID_EXPIRY_DURATION"
 	VAPID_PRIVATE_KEY_ENV     = "VAPID_PRIVATE_KEY"
 	VAPID_SUBJECT_ENV         = "VAPID_SUBJECT"
@@ -25,3 +52,19 @@ const (
 	URGENCY_NORMAL   = "normal"
 	URGENCY_HIGH     = "high"
 )
+
+const (
+	PUSH_MODE_RAW         = "raw"
+	PUSH_MODE_DECLARATIVE = "declarative"
+	PUSH_MODE_LOCALIZED   = "localized"
+	PUSH_MODE_TEMPLATE    = "template"
+)
+
+const (
+	ENCRYPTION_MODE_DIRECT   = "direct"
+	ENCRYPTION_MODE_ENVELOPE = "envelope"
+)
+
+const PUSH_TARGET_RECIPIENTS = "recipients"
+
+const DEFAULT_LOCALE = "en"
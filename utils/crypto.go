@@ -1,44 +1,79 @@
 package utils
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"bytes"
 	"crypto/rand"
-	"encoding/base64"
+	"encoding/binary"
 	"fmt"
-	"log"
 	"os"
 )
 
-func createGCM() (cipher.AEAD, error) {
-	if os.Getenv(MASTER_KEY_ENV) == "" {
-		log.Fatalf("MASTER_KEY env must be set!")
+// CIPHERTEXT_VERSION prefixes every ciphertext sealed in direct mode, followed by the ID of the master key it was sealed with.
+// Ciphertexts without the prefix were sealed before key rotation was supported, and are decrypted by trying every key.
+const CIPHERTEXT_VERSION byte = 0x01
+
+// ENVELOPE_CIPHERTEXT_VERSION prefixes every ciphertext sealed in envelope mode, followed by the ID of the key-encryption key,
+// the length of the wrapped data key as 16-bit big-endian integer, the wrapped data key, and the data sealed with the data key.
+const ENVELOPE_CIPHERTEXT_VERSION byte = 0x02
+
+// KEY_ID_SIZE is the length of a master key ID, e.g. the leading bytes of the SHA-256 digest of the key.
+const KEY_ID_SIZE = 4
+
+const DATA_KEY_SIZE = 32
+
+var errUnversioned = fmt.Errorf("ciphertext is not versioned")
+
+func getEncryptionMode() (string, error) {
+	switch mode := os.Getenv(ENCRYPTION_MODE_ENV); mode {
+	case "", ENCRYPTION_MODE_DIRECT:
+		return ENCRYPTION_MODE_DIRECT, nil
+	case ENCRYPTION_MODE_ENVELOPE:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%s env must be either %q or %q, received %q", ENCRYPTION_MODE_ENV, ENCRYPTION_MODE_DIRECT, ENCRYPTION_MODE_ENVELOPE, mode)
+	}
+}
+
+// Encrypt seals the data with the active key of the key provider, either directly or using a random data key in envelope mode.
+// The ciphertext is prefixed by the ciphertext version and the key ID.
+func Encrypt(data []byte) ([]byte, error) {
+	provider, err := getKeyProvider()
+	if err != nil {
+		return nil, err
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(os.Getenv(MASTER_KEY_ENV))
+	mode, err := getEncryptionMode()
 	if err != nil {
-		log.Fatalf("failed to decode MASTER_KEY env, make sure it's a valid base64-encoding: %v", err)
+		return nil, err
 	}
 
-	if len(decoded) != 32 {
-		log.Fatalf("MASTER_KEY env must be set and exactly 32 bytes long! Received %d bytes...", len(decoded))
+	keyID := provider.KeyIDs()[0]
+
+	if mode == ENCRYPTION_MODE_DIRECT {
+		sealed, err := provider.Encrypt(keyID, data)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(append([]byte{CIPHERTEXT_VERSION}, keyID...), sealed...), nil
 	}
 
-	aesBlock, err := aes.NewCipher(decoded)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	dataKey := make([]byte, DATA_KEY_SIZE)
+
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
 	}
 
-	gcm, err := cipher.NewGCM(aesBlock)
+	wrapped, err := provider.Encrypt(keyID, dataKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
 	}
 
-	return gcm, nil
-}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("wrapped data key too long: %d bytes", len(wrapped))
+	}
 
-func Encrypt(data []byte) ([]byte, error) {
-	gcm, err := createGCM()
+	gcm, err := newGCM(dataKey)
 	if err != nil {
 		return nil, err
 	}
@@ -49,29 +84,120 @@ func Encrypt(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to feed random data into nonce: %w", err)
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
+	prefix := append([]byte{ENVELOPE_CIPHERTEXT_VERSION}, keyID...)
+	prefix = binary.BigEndian.AppendUint16(prefix, uint16(len(wrapped)))
+	prefix = append(append(prefix, wrapped...), nonce...)
 
-	return ciphertext, nil
+	return gcm.Seal(prefix, nonce, data, nil), nil
 }
 
+// Decrypt opens a ciphertext sealed by any key of the key provider, in direct, envelope, or the legacy format.
 func Decrypt(data []byte) ([]byte, error) {
-	gcm, err := createGCM()
+	provider, err := getKeyProvider()
 	if err != nil {
 		return nil, err
 	}
 
-	nonceSize := gcm.NonceSize()
+	// a legacy nonce may start with a version byte and a known key ID by chance, hence the fallback on failure
+	plaintext, versionedErr := decryptVersioned(provider, data, nil)
+	if versionedErr == nil {
+		return plaintext, nil
+	}
+
+	for _, keyID := range provider.KeyIDs() {
+		if plaintext, err := provider.Decrypt(keyID, data); err == nil {
+			return plaintext, nil
+		}
+	}
 
-	if len(data) < nonceSize {
-		return nil, fmt.Errorf("ciphertext too short")
+	if versionedErr != errUnversioned {
+		return nil, versionedErr
 	}
 
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+	return nil, fmt.Errorf("failed to decrypt data: no key of the key provider matches the ciphertext")
+}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+// NeedsReencryption reports whether the ciphertext was sealed by a key other than the active key,
+// in another mode than the configured encryption mode, or in the legacy format.
+func NeedsReencryption(data []byte) (bool, error) {
+	provider, err := getKeyProvider()
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+		return false, err
+	}
+
+	mode, err := getEncryptionMode()
+	if err != nil {
+		return false, err
+	}
+
+	version := CIPHERTEXT_VERSION
+	if mode == ENCRYPTION_MODE_ENVELOPE {
+		version = ENVELOPE_CIPHERTEXT_VERSION
+	}
+
+	if len(data) == 0 || data[0] != version {
+		return true, nil
+	}
+
+	// only the versioned decryption using the active key rules out a legacy nonce, which happens to start with the active key ID
+	_, err = decryptVersioned(provider, data, provider.KeyIDs()[0])
+
+	return err != nil, nil
+}
+
+// Reencrypt decrypts the ciphertext using the key provider and seals it again with the active key in the configured encryption mode.
+func Reencrypt(data []byte) ([]byte, error) {
+	plaintext, err := Decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encrypt(plaintext)
+}
+
+// decryptVersioned opens a ciphertext in direct or envelope format, optionally only when sealed with the given key ID.
+func decryptVersioned(provider KeyProvider, data, onlyKeyID []byte) ([]byte, error) {
+	if len(data) < 1+KEY_ID_SIZE || (data[0] != CIPHERTEXT_VERSION && data[0] != ENVELOPE_CIPHERTEXT_VERSION) {
+		return nil, errUnversioned
+	}
+
+	keyID := data[1 : 1+KEY_ID_SIZE]
+
+	if onlyKeyID != nil && !bytes.Equal(keyID, onlyKeyID) {
+		return nil, errUnversioned
+	}
+
+	known := false
+	for _, id := range provider.KeyIDs() {
+		known = known || bytes.Equal(id, keyID)
+	}
+
+	if !known {
+		return nil, errUnversioned
+	}
+
+	rest := data[1+KEY_ID_SIZE:]
+
+	if data[0] == CIPHERTEXT_VERSION {
+		return provider.Decrypt(keyID, rest)
+	}
+
+	if len(rest) < 2 || len(rest) < 2+int(binary.BigEndian.Uint16(rest)) {
+		return nil, fmt.Errorf("envelope ciphertext too short")
+	}
+
+	wrappedSize := int(binary.BigEndian.Uint16(rest))
+	wrapped, sealed := rest[2:2+wrappedSize], rest[2+wrappedSize:]
+
+	dataKey, err := provider.Decrypt(keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
 	}
 
-	return plaintext, nil
+	return openGCM(gcm, sealed)
 }
@@ -1,6 +1,11 @@
 package utils
 
-import "testing"
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestEncrypt(t *testing.T) {
 	t.Setenv(MASTER_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
@@ -20,3 +25,252 @@ func TestEncrypt(t *testing.T) {
 		t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
 	}
 }
+
+func TestDecryptWithPreviousMasterKey(t *testing.T) {
+	oldKey := "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc="
+	newKey := "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI="
+
+	original := "Hello, World!"
+
+	t.Setenv(MASTER_KEY_ENV, oldKey)
+
+	encrypted, err := Encrypt([]byte(original))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	t.Setenv(MASTER_KEY_ENV, newKey)
+
+	if _, err := Decrypt(encrypted); err == nil {
+		t.Fatalf("Expected decryption without the previous master key to fail")
+	}
+
+	t.Setenv(PREVIOUS_MASTER_KEYS_ENV, oldKey)
+
+	decrypted, err := Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+
+	if string(decrypted) != original {
+		t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
+	}
+
+	needsReencryption, err := NeedsReencryption(encrypted)
+	if err != nil {
+		t.Fatalf("Checking for re-encryption failed: %v", err)
+	}
+
+	if !needsReencryption {
+		t.Errorf("Expected ciphertext of the previous master key to need re-encryption")
+	}
+
+	reencrypted, err := Reencrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Re-encryption failed: %v", err)
+	}
+
+	if needsReencryption, _ = NeedsReencryption(reencrypted); needsReencryption {
+		t.Errorf("Expected re-encrypted ciphertext not to need re-encryption")
+	}
+
+	t.Setenv(PREVIOUS_MASTER_KEYS_ENV, "")
+
+	decrypted, err = Decrypt(reencrypted)
+	if err != nil {
+		t.Fatalf("Decryption of re-encrypted value failed: %v", err)
+	}
+
+	if string(decrypted) != original {
+		t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
+	}
+}
+
+func TestDecryptLegacyFormat(t *testing.T) {
+	t.Setenv(MASTER_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+
+	key, _ := base64.StdEncoding.DecodeString("T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+
+	provider, err := NewLocalKeyProvider(key)
+	if err != nil {
+		t.Fatalf("Creating key provider failed: %v", err)
+	}
+
+	original := "Hello, World!"
+
+	// ciphertexts sealed before the key ID prefix consist of the nonce and the ciphertext only
+	legacy, err := provider.Encrypt(provider.KeyIDs()[0], []byte(original))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	decrypted, err := Decrypt(legacy)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+
+	if string(decrypted) != original {
+		t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
+	}
+
+	if needsReencryption, _ := NeedsReencryption(legacy); !needsReencryption {
+		t.Errorf("Expected legacy ciphertext to need re-encryption")
+	}
+}
+
+func TestEncryptEnvelope(t *testing.T) {
+	t.Setenv(MASTER_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
+	t.Setenv(ENCRYPTION_MODE_ENV, ENCRYPTION_MODE_DIRECT)
+
+	original := "Hello, World!"
+
+	direct, err := Encrypt([]byte(original))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	t.Setenv(ENCRYPTION_MODE_ENV, ENCRYPTION_MODE_ENVELOPE)
+
+	envelope, err := Encrypt([]byte(original))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	if envelope[0] != ENVELOPE_CIPHERTEXT_VERSION {
+		t.Errorf("Expected envelope ciphertext version. Got: %x", envelope[0])
+	}
+
+	for _, encrypted := range [][]byte{direct, envelope} {
+		decrypted, err := Decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+
+		if string(decrypted) != original {
+			t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
+		}
+	}
+
+	if needsReencryption, _ := NeedsReencryption(direct); !needsReencryption {
+		t.Errorf("Expected direct ciphertext to need re-encryption in envelope mode")
+	}
+
+	if needsReencryption, _ := NeedsReencryption(envelope); needsReencryption {
+		t.Errorf("Expected envelope ciphertext not to need re-encryption in envelope mode")
+	}
+
+	t.Setenv(ENCRYPTION_MODE_ENV, "unknown")
+
+	if _, err := Encrypt([]byte(original)); err == nil {
+		t.Errorf("Expected encryption to fail on unknown encryption mode")
+	}
+}
+
+func TestEncryptWithoutMasterKey(t *testing.T) {
+	t.Setenv(MASTER_KEY_ENV, "")
+	t.Setenv(MASTER_KEY_FILE_ENV, "")
+
+	if _, err := Encrypt([]byte("Hello, World!")); err == nil {
+		t.Errorf("Expected encryption to fail without master key")
+	}
+
+	t.Setenv(MASTER_KEY_ENV, "dG9vIHNob3J0")
+
+	if _, err := Encrypt([]byte("Hello, World!")); err == nil {
+		t.Errorf("Expected encryption to fail with a short master key")
+	}
+}
+
+func TestNewLocalKeyProviderFromFile(t *testing.T) {
+	oldKey := "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc="
+	newKey := "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI="
+
+	t.Setenv(MASTER_KEY_FILE_ENV, "")
+	t.Setenv(MASTER_KEY_ENV, oldKey)
+
+	original := "Hello, World!"
+
+	encrypted, err := Encrypt([]byte(original))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "master.keys")
+	content := "# active key\n" + newKey + "\n\n# previous keys\n" + oldKey + "\n"
+
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Writing key file failed: %v", err)
+	}
+
+	provider, err := NewLocalKeyProviderFromFile(path)
+	if err != nil {
+		t.Fatalf("Reading key file failed: %v", err)
+	}
+
+	if len(provider.KeyIDs()) != 2 {
+		t.Fatalf("Expected 2 keys. Got: %d", len(provider.KeyIDs()))
+	}
+
+	t.Setenv(MASTER_KEY_ENV, "")
+	t.Setenv(MASTER_KEY_FILE_ENV, path)
+
+	decrypted, err := Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+
+	if string(decrypted) != original {
+		t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
+	}
+
+	if needsReencryption, _ := NeedsReencryption(encrypted); !needsReencryption {
+		t.Errorf("Expected ciphertext of the previous key to need re-encryption")
+	}
+}
+
+type reversingKeyProvider struct{}
+
+func (reversingKeyProvider) KeyIDs() [][]byte {
+	return [][]byte{[]byte("test")}
+}
+
+func (reversingKeyProvider) Encrypt(keyID, plaintext []byte) ([]byte, error) {
+	reversed := make([]byte, len(plaintext))
+	for i, b := range plaintext {
+		reversed[len(plaintext)-1-i] = b
+	}
+	return reversed, nil
+}
+
+func (p reversingKeyProvider) Decrypt(keyID, ciphertext []byte) ([]byte, error) {
+	return p.Encrypt(keyID, ciphertext)
+}
+
+func TestSetKeyProvider(t *testing.T) {
+	t.Setenv(MASTER_KEY_ENV, "")
+	t.Setenv(MASTER_KEY_FILE_ENV, "")
+	t.Setenv(ENCRYPTION_MODE_ENV, ENCRYPTION_MODE_ENVELOPE)
+
+	SetKeyProvider(reversingKeyProvider{})
+	defer SetKeyProvider(nil)
+
+	original := "Hello, World!"
+
+	encrypted, err := Encrypt([]byte(original))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	if string(encrypted[1:1+KEY_ID_SIZE]) != "test" {
+		t.Errorf("Expected key ID of the custom key provider. Got: %x", encrypted[1:1+KEY_ID_SIZE])
+	}
+
+	decrypted, err := Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+
+	if string(decrypted) != original {
+		t.Errorf("Decrypted value does not match original. Got: %s, want: %s", decrypted, original)
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
+// PLAIN_HASH_SECRET denotes plain SHA-256 in the PREVIOUS_HMAC_SECRET_KEYS env, i.e. hashes computed while HMAC_SECRET_KEY was unset.
+const PLAIN_HASH_SECRET = "sha256"
+
 func Hash(data []byte) (hashed [32]byte) {
 	secret := os.Getenv(HMAC_SECRET_KEY_ENV)
 
@@ -25,7 +30,60 @@ func Hash(data []byte) (hashed [32]byte) {
 	}
 
 	// If the secret is set and valid, we can use it to create a more secure hash
-	mac := hmac.New(sha256.New, decoded)
+	return hashWithSecret(decoded, data)
+}
+
+// Hashes returns the hash of the data using the active HMAC_SECRET_KEY, followed by the hashes using the previous secrets,
+// which allows lookups to match rows not yet rehashed during a rotation of the secret.
+func Hashes(data []byte) [][]byte {
+	active := Hash(data)
+	hashes := [][]byte{active[:]}
+
+	for _, secret := range strings.Split(os.Getenv(PREVIOUS_HMAC_SECRET_KEYS_ENV), ",") {
+		var hashed [32]byte
+
+		switch secret = strings.TrimSpace(secret); secret {
+		case "":
+			continue
+		case PLAIN_HASH_SECRET:
+			hashed = sha256.Sum256(data)
+		default:
+			decoded, err := base64.StdEncoding.DecodeString(secret)
+			if len(decoded) == 0 || err != nil {
+				log.Printf("failed to decode PREVIOUS_HMAC_SECRET_KEYS env, make sure it's a comma-separated list of base64-encoded secrets: %v", err)
+				continue
+			}
+
+			hashed = hashWithSecret(decoded, data)
+		}
+
+		hashes = append(hashes, hashed[:])
+	}
+
+	return hashes
+}
+
+// ValidateHashSecret fails when HMAC_SECRET_KEY is unset or invalid, unless plain SHA-256 hashes were explicitly allowed using HMAC_ALLOW_INSECURE.
+func ValidateHashSecret() error {
+	if os.Getenv(HMAC_ALLOW_INSECURE_ENV) == "true" {
+		return nil
+	}
+
+	secret := os.Getenv(HMAC_SECRET_KEY_ENV)
+
+	if secret == "" {
+		return fmt.Errorf("%s env not set, set %s=true to fall back to plain SHA-256", HMAC_SECRET_KEY_ENV, HMAC_ALLOW_INSECURE_ENV)
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(secret); len(decoded) == 0 || err != nil {
+		return fmt.Errorf("failed to decode %s env, make sure it's a valid base64-encoding: %v", HMAC_SECRET_KEY_ENV, err)
+	}
+
+	return nil
+}
+
+func hashWithSecret(secret, data []byte) (hashed [32]byte) {
+	mac := hmac.New(sha256.New, secret)
 	mac.Write(data)
 	copy(hashed[:], mac.Sum(nil))
 
@@ -1,6 +1,9 @@
 package utils
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func TestHash(t *testing.T) {
 	t.Setenv(HMAC_SECRET_KEY_ENV, "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=")
@@ -25,3 +28,65 @@ func TestHashWithoutSecret(t *testing.T) {
 		t.Errorf("Hash function is not deterministic without secret. Got: %x and %x", hashed1, hashed2)
 	}
 }
+
+func TestHashes(t *testing.T) {
+	oldSecret := "l342tf9eC2l4/fVytEkkzQzYyqd3eKd6GViw65WB5yI="
+	newSecret := "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc="
+
+	data := []byte("Hello, World!")
+
+	t.Setenv(HMAC_SECRET_KEY_ENV, "")
+	plain := Hash(data)
+
+	t.Setenv(HMAC_SECRET_KEY_ENV, oldSecret)
+	old := Hash(data)
+
+	t.Setenv(HMAC_SECRET_KEY_ENV, newSecret)
+	active := Hash(data)
+
+	t.Setenv(PREVIOUS_HMAC_SECRET_KEYS_ENV, "")
+
+	if hashes := Hashes(data); len(hashes) != 1 || !bytes.Equal(hashes[0], active[:]) {
+		t.Errorf("Expected only the hash of the active secret. Got: %x", hashes)
+	}
+
+	t.Setenv(PREVIOUS_HMAC_SECRET_KEYS_ENV, oldSecret+", "+PLAIN_HASH_SECRET)
+
+	hashes := Hashes(data)
+	want := [][]byte{active[:], old[:], plain[:]}
+
+	if len(hashes) != len(want) {
+		t.Fatalf("Expected %d hashes. Got: %d", len(want), len(hashes))
+	}
+
+	for i := range want {
+		if !bytes.Equal(hashes[i], want[i]) {
+			t.Errorf("Hash %d does not match. Got: %x, want: %x", i, hashes[i], want[i])
+		}
+	}
+}
+
+func TestValidateHashSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		secret        string
+		allowInsecure string
+		wantErr       bool
+	}{
+		{"should accept a valid secret", "T5p2WRcCKFSA6vhXlBEqyDBxNsWHSkydLadEhLL1eGc=", "", false},
+		{"should refuse a missing secret", "", "", true},
+		{"should refuse an invalid secret", "not base64!", "", true},
+		{"should accept a missing secret when explicitly allowed", "", "true", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(HMAC_SECRET_KEY_ENV, tt.secret)
+			t.Setenv(HMAC_ALLOW_INSECURE_ENV, tt.allowInsecure)
+
+			if err := ValidateHashSecret(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHashSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeyProvider supplies the keys of the column encryption, e.g. local master keys or the key-encryption keys of a cloud KMS.
+// In direct mode, the provider seals the column data itself, in envelope mode it only seals the per-row data keys.
+type KeyProvider interface {
+	// KeyIDs returns the KEY_ID_SIZE bytes long IDs of all keys, the first one being the active key, which new data is sealed with.
+	// The other keys are only used for decryption, e.g. during a key rotation.
+	KeyIDs() [][]byte
+	// Encrypt seals the plaintext with the key of the given ID.
+	Encrypt(keyID, plaintext []byte) ([]byte, error)
+	// Decrypt opens a ciphertext sealed with the key of the given ID.
+	Decrypt(keyID, ciphertext []byte) ([]byte, error)
+}
+
+// LocalKeyProvider holds 32-byte AES-GCM master keys in memory, identified by the leading bytes of their SHA-256 digest.
+type LocalKeyProvider struct {
+	ids  [][]byte
+	gcms map[string]cipher.AEAD
+}
+
+var _ KeyProvider = (*LocalKeyProvider)(nil)
+
+// NewLocalKeyProvider creates a provider of the given keys, the first one being the active key.
+func NewLocalKeyProvider(keys ...[]byte) (*LocalKeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one master key is required")
+	}
+
+	p := &LocalKeyProvider{
+		ids:  make([][]byte, 0, len(keys)),
+		gcms: make(map[string]cipher.AEAD, len(keys)),
+	}
+
+	for _, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key must be exactly 32 bytes long, received %d bytes", len(key))
+		}
+
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+
+		digest := sha256.Sum256(key)
+		id := digest[:KEY_ID_SIZE]
+
+		if _, ok := p.gcms[string(id)]; ok {
+			continue
+		}
+
+		p.ids = append(p.ids, id)
+		p.gcms[string(id)] = gcm
+	}
+
+	return p, nil
+}
+
+// NewLocalKeyProviderFromFile reads base64-encoded master keys from a file, one per line, the first one being the active key.
+// Empty lines and lines starting with # are ignored.
+func NewLocalKeyProviderFromFile(path string) (*LocalKeyProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+
+	keys := make([][]byte, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode master key file, make sure it contains one base64-encoded key per line: %w", err)
+		}
+
+		keys = append(keys, decoded)
+	}
+
+	return NewLocalKeyProvider(keys...)
+}
+
+// NewLocalKeyProviderFromEnv reads the master keys from the file in the MASTER_KEY_FILE env,
+// or otherwise from the MASTER_KEY env, followed by the PREVIOUS_MASTER_KEYS env.
+func NewLocalKeyProviderFromEnv() (*LocalKeyProvider, error) {
+	if path := os.Getenv(MASTER_KEY_FILE_ENV); path != "" {
+		return NewLocalKeyProviderFromFile(path)
+	}
+
+	if os.Getenv(MASTER_KEY_ENV) == "" {
+		return nil, fmt.Errorf("either %s or %s env must be set", MASTER_KEY_ENV, MASTER_KEY_FILE_ENV)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(os.Getenv(MASTER_KEY_ENV))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s env, make sure it's a valid base64-encoding: %w", MASTER_KEY_ENV, err)
+	}
+
+	keys := [][]byte{decoded}
+
+	for _, encoded := range strings.Split(os.Getenv(PREVIOUS_MASTER_KEYS_ENV), ",") {
+		if encoded = strings.TrimSpace(encoded); encoded == "" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s env, make sure it's a comma-separated list of base64-encoded keys: %w", PREVIOUS_MASTER_KEYS_ENV, err)
+		}
+
+		keys = append(keys, decoded)
+	}
+
+	return NewLocalKeyProvider(keys...)
+}
+
+func (p *LocalKeyProvider) KeyIDs() [][]byte {
+	return p.ids
+}
+
+func (p *LocalKeyProvider) Encrypt(keyID, plaintext []byte) ([]byte, error) {
+	gcm, ok := p.gcms[string(keyID)]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key ID %x", keyID)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to feed random data into nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (p *LocalKeyProvider) Decrypt(keyID, ciphertext []byte) ([]byte, error) {
+	gcm, ok := p.gcms[string(keyID)]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key ID %x", keyID)
+	}
+
+	return openGCM(gcm, ciphertext)
+}
+
+// keyProviderCache holds the key provider, which is either set explicitly, or created from the env,
+// and recreated once the env changes. A master key file is only read again, when its path changes.
+var keyProviderCache struct {
+	sync.Mutex
+
+	provider KeyProvider
+	custom   bool
+	env      string
+}
+
+// SetKeyProvider replaces the key provider created from the env, e.g. by a cloud KMS adapter. Passing nil restores the default.
+func SetKeyProvider(provider KeyProvider) {
+	keyProviderCache.Lock()
+	defer keyProviderCache.Unlock()
+
+	keyProviderCache.provider = provider
+	keyProviderCache.custom = provider != nil
+	keyProviderCache.env = ""
+}
+
+func getKeyProvider() (KeyProvider, error) {
+	keyProviderCache.Lock()
+	defer keyProviderCache.Unlock()
+
+	if keyProviderCache.custom {
+		return keyProviderCache.provider, nil
+	}
+
+	env := strings.Join([]string{os.Getenv(MASTER_KEY_FILE_ENV), os.Getenv(MASTER_KEY_ENV), os.Getenv(PREVIOUS_MASTER_KEYS_ENV)}, "\x00")
+
+	if keyProviderCache.provider != nil && keyProviderCache.env == env {
+		return keyProviderCache.provider, nil
+	}
+
+	provider, err := NewLocalKeyProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	keyProviderCache.provider = provider
+	keyProviderCache.env = env
+
+	return provider, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	aesBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(aesBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
+
+func openGCM(gcm cipher.AEAD, data []byte) ([]byte, error) {
+	nonceSize := gcm.NonceSize()
+
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+func GetDefaultLocale() string {
+	env := os.Getenv(DEFAULT_LOCALE_ENV)
+
+	if env == "" {
+		return DEFAULT_LOCALE
+	}
+
+	if _, err := language.Parse(env); err != nil {
+		log.Printf("failed to parse %s env, falling back to default: %s\n", DEFAULT_LOCALE_ENV, DEFAULT_LOCALE)
+		return DEFAULT_LOCALE
+	}
+
+	return env
+}
+
+// LocaleFallbackChain returns the given locale followed by its less specific parents and the fallback locale,
+// e.g. "de-AT" results in ["de-at", "de", "en"]. All entries are lowercased for case-insensitive matching.
+func LocaleFallbackChain(locale, fallback string) (chain []string) {
+	seen := map[string]bool{}
+
+	add := func(l string) {
+		l = strings.ToLower(l)
+
+		if l == "" || seen[l] {
+			return
+		}
+
+		seen[l] = true
+		chain = append(chain, l)
+	}
+
+	parts := strings.Split(strings.ReplaceAll(locale, "_", "-"), "-")
+
+	for i := len(parts); i > 0; i-- {
+		add(strings.Join(parts[:i], "-"))
+	}
+
+	add(fallback)
+
+	return
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocaleFallbackChain(t *testing.T) {
+	type test struct {
+		name     string
+		locale   string
+		fallback string
+		want     []string
+	}
+
+	tests := []test{
+		{"region falls back to language and default", "de-AT", "en", []string{"de-at", "de", "en"}},
+		{"script and region fall back step by step", "zh-Hant-TW", "en", []string{"zh-hant-tw", "zh-hant", "zh", "en"}},
+		{"underscores are treated as separators", "pt_BR", "en", []string{"pt-br", "pt", "en"}},
+		{"default is not repeated", "en-US", "en", []string{"en-us", "en"}},
+		{"empty locale returns default only", "", "en", []string{"en"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LocaleFallbackChain(tt.locale, tt.fallback); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LocaleFallbackChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDefaultLocale(t *testing.T) {
+	t.Setenv(DEFAULT_LOCALE_ENV, "")
+
+	if got := GetDefaultLocale(); got != DEFAULT_LOCALE {
+		t.Errorf("GetDefaultLocale() = %s, want %s", got, DEFAULT_LOCALE)
+	}
+
+	t.Setenv(DEFAULT_LOCALE_ENV, "de-AT")
+
+	if got := GetDefaultLocale(); got != "de-AT" {
+		t.Errorf("GetDefaultLocale() = %s, want %s", got, "de-AT")
+	}
+}
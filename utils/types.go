@@ -237,8 +237,10 @@ type RecipientSubscription struct {
 }
 
 type Recipient struct {
-	ClientId    string `json:"clientId" validate:"required"`
-	RecipientId string `json:"id" validate:"required"`
+	ClientId    string   `json:"clientId" validate:"required"`
+	RecipientId string   `json:"id" validate:"required"`
+	Locale      string   `json:"locale,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Tags        []string `json:"tags,omitempty" validate:"omitempty,max=32,dive,tag"`
 
 	Subscription *RecipientSubscription `json:"subscription" validate:"required"`
 }
@@ -3,18 +3,31 @@ package utils
 import (
 	"fmt"
 	"log"
+	"reflect"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 )
 
 const (
-	EPOCH_GT_NOW = "epoch-gt-now"
-	MAILTO       = "mailto"
-	ORIGIN       = "origin"
+	EPOCH_GT_NOW   = "epoch-gt-now"
+	MAILTO         = "mailto"
+	MAX_RECIPIENTS = "max-recipients"
+	ORIGIN         = "origin"
+	TAG            = "tag"
+	TOPIC          = "topic"
 )
 
+// MAX_PUSH_RECIPIENTS limits the recipient IDs and batch entries of a single push request.
+const MAX_PUSH_RECIPIENTS = 1000
+
+var tagRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9:._/-]{0,254}$`)
+
+// TopicRegex matches the Topic header of push messages, see https://datatracker.ietf.org/doc/html/rfc8030#section-5.4
+var TopicRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
 var _customValidator *validator.Validate
 
 func CustomValidateStruct(s any) (err error) {
@@ -49,7 +62,10 @@ func NewCustomValidator() *validator.Validate {
 	}{
 		{EPOCH_GT_NOW, validateEpochGreaterNow},
 		{MAILTO, validateMailto},
+		{MAX_RECIPIENTS, validateMaxRecipients},
 		{ORIGIN, validateOrigin},
+		{TAG, validateTag},
+		{TOPIC, validateTopic},
 	}
 
 	for _, vv := range customValidators {
@@ -96,6 +112,16 @@ func validateMailto(fl validator.FieldLevel) bool {
 	return r.MatchString(val)
 }
 
+func validateMaxRecipients(fl validator.FieldLevel) bool {
+	field := fl.Field()
+
+	if field.Kind() != reflect.Slice {
+		return false
+	}
+
+	return field.Len() <= MAX_PUSH_RECIPIENTS
+}
+
 func validateOrigin(fl validator.FieldLevel) bool {
 	val, ok := fl.Field().Interface().(string)
 
@@ -108,3 +134,34 @@ func validateOrigin(fl validator.FieldLevel) bool {
 
 	return r.MatchString(val)
 }
+
+// IsValidTag reports whether s may be used as a subscription tag. Tags must not contain whitespace or parentheses
+// and must not equal one of the tag expression operators AND, OR and NOT.
+func IsValidTag(s string) bool {
+	switch strings.ToUpper(s) {
+	case "AND", "OR", "NOT":
+		return false
+	}
+
+	return tagRegex.MatchString(s)
+}
+
+func validateTag(fl validator.FieldLevel) bool {
+	val, ok := fl.Field().Interface().(string)
+
+	if !ok {
+		return ok
+	}
+
+	return IsValidTag(val)
+}
+
+func validateTopic(fl validator.FieldLevel) bool {
+	val, ok := fl.Field().Interface().(string)
+
+	if !ok {
+		return ok
+	}
+
+	return TopicRegex.MatchString(val)
+}
@@ -60,6 +60,33 @@ func TestCustomValidator(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"valid topic",
+			struct {
+				Val string `validate:"topic"`
+			}{
+				"news_2026-10",
+			},
+			false,
+		},
+		{
+			"invalid topic",
+			struct {
+				Val string `validate:"topic"`
+			}{
+				"breaking news",
+			},
+			true,
+		},
+		{
+			"too long topic",
+			struct {
+				Val string `validate:"topic"`
+			}{
+				"abcdefghijklmnopqrstuvwxyz0123456",
+			},
+			true,
+		},
 		{
 			"valid mailto",
 			struct {
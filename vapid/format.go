@@ -0,0 +1,219 @@
+package vapid
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+)
+
+const (
+	FORMAT_RAW   = "raw"   // base64url-encoded private scalar, as generated by the web-push npm package
+	FORMAT_SEC1  = "sec1"  // PEM-encoded "EC PRIVATE KEY"
+	FORMAT_PKCS8 = "pkcs8" // PEM-encoded "PRIVATE KEY"
+	FORMAT_JWK   = "jwk"   // JSON Web Key, see https://datatracker.ietf.org/doc/html/rfc7518#section-6.2
+
+	PRIVATE_KEY_SIZE = 32
+)
+
+var FORMATS = []string{FORMAT_RAW, FORMAT_SEC1, FORMAT_PKCS8, FORMAT_JWK}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	D   string `json:"d,omitempty"`
+}
+
+// DecodeBase64 accepts base64url- and base64-encoded input, with or without padding.
+func DecodeBase64(enc string) ([]byte, error) {
+	enc = strings.TrimRight(strings.TrimSpace(enc), "=")
+	enc = strings.NewReplacer("+", "-", "/", "_").Replace(enc)
+
+	return base64.RawURLEncoding.DecodeString(enc)
+}
+
+func newVapidKey(d []byte) (k *vapidKey, err error) {
+	var ecdhKey *ecdh.PrivateKey
+
+	if ecdhKey, err = ecdh.P256().NewPrivateKey(d); err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("private key is no valid P-256 scalar")
+	}
+
+	// uncompressed point format: 0x04 || X || Y
+	pub := ecdhKey.PublicKey().Bytes()
+
+	return &vapidKey{
+		&ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+	}, nil
+}
+
+// DecodeFromRaw decodes a base64url-encoded private scalar, as used by the web-push npm package and most other libraries.
+func DecodeFromRaw(privateKey string) (k *vapidKey, err error) {
+	var d []byte
+
+	if d, err = DecodeBase64(privateKey); err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("failed to decode base64url private key")
+	}
+
+	if len(d) != PRIVATE_KEY_SIZE {
+		return nil, fmt.Errorf("private key has wrong size: %d bytes, expected %d", len(d), PRIVATE_KEY_SIZE)
+	}
+
+	return newVapidKey(d)
+}
+
+// DecodeFromJWK decodes an EC P-256 private key in JSON Web Key format.
+func DecodeFromJWK(encoded string) (k *vapidKey, err error) {
+	key := &jwk{}
+
+	if err = json.Unmarshal([]byte(encoded), key); err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("failed to parse JWK")
+	}
+
+	if key.Kty != "EC" || key.Crv != "P-256" {
+		return nil, fmt.Errorf("only EC keys on the P-256 curve are supported, got kty: %q, crv: %q", key.Kty, key.Crv)
+	}
+
+	if key.D == "" {
+		return nil, fmt.Errorf("JWK contains no private key")
+	}
+
+	if k, err = DecodeFromRaw(key.D); err != nil {
+		return
+	}
+
+	pub := []byte{0x04}
+
+	for _, coordinate := range []string{key.X, key.Y} {
+		var buf []byte
+
+		if buf, err = DecodeBase64(coordinate); err != nil || len(buf) != PRIVATE_KEY_SIZE {
+			return nil, fmt.Errorf("JWK contains an invalid public key coordinate")
+		}
+
+		pub = append(pub, buf...)
+	}
+
+	if err = k.MatchesPublicKey(base64.RawURLEncoding.EncodeToString(pub)); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Decode detects the format of the private key, which may be a SEC1 or PKCS#8 PEM, a JWK or a raw base64url-encoded scalar.
+func Decode(encoded string) (k *vapidKey, err error) {
+	encoded = strings.TrimSpace(encoded)
+
+	switch {
+	case strings.HasPrefix(encoded, "-----BEGIN"):
+		return DecodeFromPEM(encoded)
+	case strings.HasPrefix(encoded, "{"):
+		return DecodeFromJWK(encoded)
+	default:
+		return DecodeFromRaw(encoded)
+	}
+}
+
+// MatchesPublicKey checks, whether the base64url-encoded public key belongs to the private key.
+// Keeping the public key is mandatory when migrating, as subscriptions are bound to it via applicationServerKey.
+func (k *vapidKey) MatchesPublicKey(publicKey string) (err error) {
+	var buf []byte
+
+	if buf, err = DecodeBase64(publicKey); err != nil {
+		log.Println(err)
+		return fmt.Errorf("failed to decode base64url public key")
+	}
+
+	if base64.RawURLEncoding.EncodeToString(buf) != k.String() {
+		return fmt.Errorf("public key does not match the private key")
+	}
+
+	return
+}
+
+// EncodeToRaw returns the base64url-encoded private scalar.
+func (k *vapidKey) EncodeToRaw() (s string, err error) {
+	var ecdhKey *ecdh.PrivateKey
+
+	if ecdhKey, err = k.PrivateKey.ECDH(); err != nil {
+		log.Println(err)
+		return "", fmt.Errorf("failed to convert ECDSA private key")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(ecdhKey.Bytes()), nil
+}
+
+// EncodeToPKCS8PEM returns the private key as PKCS#8 PEM, whereas EncodeToPEM returns SEC1.
+func (k *vapidKey) EncodeToPKCS8PEM() (p string, err error) {
+	var encoded []byte
+
+	if encoded, err = x509.MarshalPKCS8PrivateKey(k.PrivateKey); err != nil {
+		log.Println(err)
+		return "", fmt.Errorf("failed to encode ECDSA private key to PKCS#8 format")
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})), nil
+}
+
+// EncodeToJWK returns the key in JSON Web Key format, optionally including the private key.
+func (k *vapidKey) EncodeToJWK(isPrivate bool) (s string, err error) {
+	var ecdhKey *ecdh.PrivateKey
+
+	if ecdhKey, err = k.PrivateKey.ECDH(); err != nil {
+		log.Println(err)
+		return "", fmt.Errorf("failed to convert ECDSA private key")
+	}
+
+	pub := ecdhKey.PublicKey().Bytes()
+
+	key := &jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(pub[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(pub[33:]),
+	}
+
+	if isPrivate {
+		key.D = base64.RawURLEncoding.EncodeToString(ecdhKey.Bytes())
+	}
+
+	buf, err := json.Marshal(key)
+
+	return string(buf), err
+}
+
+// Encode returns the private key in one of the FORMATS.
+func (k *vapidKey) Encode(format string) (s string, err error) {
+	switch format {
+	case FORMAT_RAW:
+		return k.EncodeToRaw()
+	case FORMAT_SEC1:
+		return k.EncodeToPEM(true)
+	case FORMAT_PKCS8:
+		return k.EncodeToPKCS8PEM()
+	case FORMAT_JWK:
+		return k.EncodeToJWK(true)
+	default:
+		return "", fmt.Errorf("unsupported format: %q, expected one of %s", format, strings.Join(FORMATS, ", "))
+	}
+}
@@ -0,0 +1,83 @@
+package vapid
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// key pair taken from Appendix A. of https://datatracker.ietf.org/doc/rfc8291/
+const (
+	testPrivateKey = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	testPublicKey  = "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"
+)
+
+func TestDecodeFromRaw(t *testing.T) {
+	k, err := DecodeFromRaw(testPrivateKey)
+	assert.NilError(t, err)
+
+	assert.Equal(t, k.String(), testPublicKey)
+	assert.NilError(t, k.MatchesPublicKey(testPublicKey))
+
+	other, err := GenerateVapidKey()
+	assert.NilError(t, err)
+
+	assert.ErrorContains(t, k.MatchesPublicKey(other.String()), "does not match")
+
+	_, err = DecodeFromRaw("c2hvcnQ")
+	assert.ErrorContains(t, err, "wrong size")
+
+	_, err = DecodeFromRaw(strings.Repeat("_", 43))
+	assert.ErrorContains(t, err, "no valid P-256 scalar")
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	k, err := DecodeFromRaw(testPrivateKey)
+	assert.NilError(t, err)
+
+	for _, format := range FORMATS {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := k.Encode(format)
+			assert.NilError(t, err)
+
+			decoded, err := Decode(encoded)
+			assert.NilError(t, err)
+
+			raw, err := decoded.EncodeToRaw()
+			assert.NilError(t, err)
+
+			assert.Equal(t, raw, testPrivateKey)
+			assert.Equal(t, decoded.String(), testPublicKey)
+		})
+	}
+
+	_, err = k.Encode("der")
+	assert.ErrorContains(t, err, "unsupported format")
+}
+
+func TestDecodeFromJWK(t *testing.T) {
+	k, err := DecodeFromRaw(testPrivateKey)
+	assert.NilError(t, err)
+
+	public, err := k.EncodeToJWK(false)
+	assert.NilError(t, err)
+
+	_, err = DecodeFromJWK(public)
+	assert.ErrorContains(t, err, "no private key")
+
+	other, err := GenerateVapidKey()
+	assert.NilError(t, err)
+
+	otherJWK, err := other.EncodeToJWK(true)
+	assert.NilError(t, err)
+
+	// combines the private key with the public key of another key pair
+	mismatched := strings.Replace(otherJWK, otherJWK[strings.Index(otherJWK, `"d":`):], `"d":"`+testPrivateKey+`"}`, 1)
+
+	_, err = DecodeFromJWK(mismatched)
+	assert.ErrorContains(t, err, "does not match")
+
+	_, err = DecodeFromJWK(`{"kty":"RSA"}`)
+	assert.ErrorContains(t, err, "only EC keys")
+}
@@ -0,0 +1,165 @@
+package webpush
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/saschazar21/go-web-push-server/errors"
+	"github.com/saschazar21/go-web-push-server/utils"
+)
+
+const DECLARATIVE_WEB_PUSH_MAGIC = 8030 // see https://webkit.org/blog/16535/meet-declarative-web-push/
+
+type DeclarativeAction struct {
+	Action   string `json:"action" validate:"required"`
+	Title    string `json:"title" validate:"required"`
+	Navigate string `json:"navigate" validate:"required,url"`
+	Icon     string `json:"icon,omitempty" validate:"omitempty,url"`
+}
+
+type DeclarativeNotification struct {
+	Title              string               `json:"title" validate:"required"`
+	Navigate           string               `json:"navigate" validate:"required,url"`
+	Body               string               `json:"body,omitempty"`
+	Lang               string               `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Dir                string               `json:"dir,omitempty" validate:"omitempty,oneof=auto ltr rtl"`
+	Tag                string               `json:"tag,omitempty"`
+	Icon               string               `json:"icon,omitempty" validate:"omitempty,url"`
+	Image              string               `json:"image,omitempty" validate:"omitempty,url"`
+	Badge              string               `json:"badge,omitempty" validate:"omitempty,url"`
+	Timestamp          *utils.EpochMillis   `json:"timestamp,omitempty"`
+	Renotify           bool                 `json:"renotify,omitempty"`
+	Silent             bool                 `json:"silent,omitempty"`
+	RequireInteraction bool                 `json:"require_interaction,omitempty"`
+	Data               any                  `json:"data,omitempty"`
+	Actions            []*DeclarativeAction `json:"actions,omitempty" validate:"omitempty,dive"`
+}
+
+// DeclarativeFallback contains the top-level fields read by service-worker-based browsers,
+// which do not support Declarative Web Push and therefore ignore the notification member.
+type DeclarativeFallback struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+type DeclarativePayload struct {
+	WebPush      int                      `json:"web_push" validate:"eq=8030"`
+	Notification *DeclarativeNotification `json:"notification" validate:"required"`
+	AppBadge     *uint64                  `json:"app_badge,omitempty"`
+	Mutable      bool                     `json:"mutable,omitempty"`
+
+	*DeclarativeFallback `validate:"-"`
+}
+
+func (p *DeclarativePayload) WithAppBadge(count uint64) *DeclarativePayload {
+	p.AppBadge = &count
+
+	return p
+}
+
+func (p *DeclarativePayload) WithFallback() *DeclarativePayload {
+	p.DeclarativeFallback = &DeclarativeFallback{
+		Title: p.Notification.Title,
+		Body:  p.Notification.Body,
+		Icon:  p.Notification.Icon,
+		Tag:   p.Notification.Tag,
+		URL:   p.Notification.Navigate,
+	}
+
+	return p
+}
+
+func (p *DeclarativePayload) WithMutable() *DeclarativePayload {
+	p.Mutable = true
+
+	return p
+}
+
+func (p *DeclarativePayload) Bytes() (buf []byte, err error) {
+	if err = p.Validate(); err != nil {
+		return
+	}
+
+	if buf, err = json.Marshal(p); err != nil {
+		log.Printf("encoding declarative payload failed: %v", err)
+
+		return nil, errors.NewResponseError(errors.INTERNAL_SERVER_ERROR, http.StatusInternalServerError)
+	}
+
+	return
+}
+
+func (p *DeclarativePayload) Validate() (err error) {
+	if err = utils.CustomValidateStruct(p); err != nil {
+		log.Printf("invalid declarative payload: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "Invalid declarative payload", err.Error())
+		return errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return
+}
+
+func NewDeclarativePayload(notification *DeclarativeNotification) *DeclarativePayload {
+	return &DeclarativePayload{
+		WebPush:      DECLARATIVE_WEB_PUSH_MAGIC,
+		Notification: notification,
+	}
+}
+
+// DeclarativeRequest is the simplified request body accepted by the push endpoint in declarative mode.
+type DeclarativeRequest struct {
+	Title    string  `json:"title"`
+	Body     string  `json:"body,omitempty"`
+	Navigate string  `json:"navigate"`
+	Icon     string  `json:"icon,omitempty"`
+	Tag      string  `json:"tag,omitempty"`
+	Lang     string  `json:"lang,omitempty"`
+	Silent   bool    `json:"silent,omitempty"`
+	AppBadge *uint64 `json:"appBadge,omitempty"`
+	Mutable  bool    `json:"mutable,omitempty"`
+	Data     any     `json:"data,omitempty"`
+	Fallback bool    `json:"fallback,omitempty"`
+}
+
+func (r *DeclarativeRequest) Payload() *DeclarativePayload {
+	p := NewDeclarativePayload(&DeclarativeNotification{
+		Title:    r.Title,
+		Navigate: r.Navigate,
+		Body:     r.Body,
+		Icon:     r.Icon,
+		Tag:      r.Tag,
+		Lang:     r.Lang,
+		Silent:   r.Silent,
+		Data:     r.Data,
+	})
+
+	if r.AppBadge != nil {
+		p.WithAppBadge(*r.AppBadge)
+	}
+
+	if r.Mutable {
+		p.WithMutable()
+	}
+
+	if r.Fallback {
+		p.WithFallback()
+	}
+
+	return p
+}
+
+func NewDeclarativePayloadFromRequest(body []byte) (buf []byte, err error) {
+	r := &DeclarativeRequest{}
+
+	if err = json.Unmarshal(body, r); err != nil {
+		log.Printf("decoding declarative request failed: %v", err)
+		payload := errors.NewErrorResponse(http.StatusBadRequest, "failed to parse JSON body")
+		return nil, errors.NewResponseError(payload, http.StatusBadRequest)
+	}
+
+	return r.Payload().Bytes()
+}
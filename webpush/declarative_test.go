@@ -0,0 +1,96 @@
+package webpush
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeclarativePayload(t *testing.T) {
+	type test struct {
+		name    string
+		body    string
+		wantErr bool
+		want    map[string]any
+	}
+
+	tests := []test{
+		{
+			"wraps simple request",
+			`{"title":"Hello","body":"World","navigate":"https://example.com/inbox"}`,
+			false,
+			map[string]any{
+				"web_push": float64(DECLARATIVE_WEB_PUSH_MAGIC),
+				"notification": map[string]any{
+					"title":    "Hello",
+					"body":     "World",
+					"navigate": "https://example.com/inbox",
+				},
+			},
+		},
+		{
+			"adds fallback fields and app badge",
+			`{"title":"Hello","navigate":"https://example.com","icon":"https://example.com/icon.png","appBadge":3,"fallback":true}`,
+			false,
+			map[string]any{
+				"web_push": float64(DECLARATIVE_WEB_PUSH_MAGIC),
+				"notification": map[string]any{
+					"title":    "Hello",
+					"navigate": "https://example.com",
+					"icon":     "https://example.com/icon.png",
+				},
+				"app_badge": float64(3),
+				"title":     "Hello",
+				"icon":      "https://example.com/icon.png",
+				"url":       "https://example.com",
+			},
+		},
+		{
+			"fails on missing title",
+			`{"navigate":"https://example.com"}`,
+			true,
+			nil,
+		},
+		{
+			"fails on missing navigate",
+			`{"title":"Hello"}`,
+			true,
+			nil,
+		},
+		{
+			"fails on invalid navigate URL",
+			`{"title":"Hello","navigate":"not a url"}`,
+			true,
+			nil,
+		},
+		{
+			"fails on malformed JSON",
+			`{"title":`,
+			true,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := NewDeclarativePayloadFromRequest([]byte(tt.body))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TestDeclarativePayload err = %v, wantErr = %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			var got map[string]any
+
+			if err = json.Unmarshal(buf, &got); err != nil {
+				t.Fatalf("TestDeclarativePayload err = %v, wantErr = %v", err, nil)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
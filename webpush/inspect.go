@@ -0,0 +1,151 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	HEADER_SIZE      = SALT_SIZE + RECORD_SIZE + 1 // salt || rs || idlen, followed by the key ID
+	KEY_ID_SIZE      = 65                          // uncompressed P-256 public key
+	MIN_RECORD_SIZE  = 18                          // see https://datatracker.ietf.org/doc/html/rfc8188#section-2.1
+	RECORD_DELIMITER = 0x02                        // the padding delimiter of the last record
+)
+
+// ContentCodingHeader is the parsed aes128gcm content coding header, see https://datatracker.ietf.org/doc/html/rfc8188#section-2.1
+type ContentCodingHeader struct {
+	Salt       []byte
+	RecordSize uint32
+	KeyId      []byte
+	PublicKey  *ecdh.PublicKey // the application server's ephemeral public key, contained in the key ID
+}
+
+// DecryptedRecord is the plaintext of a push message body, along with its padding.
+type DecryptedRecord struct {
+	Plaintext     []byte
+	Delimiter     byte
+	PaddingLength int
+}
+
+// ParseContentCodingHeader parses the header of a body produced by WebPush.Encrypt, returning the remaining ciphertext.
+func ParseContentCodingHeader(body []byte) (header *ContentCodingHeader, ciphertext []byte, err error) {
+	if len(body) < HEADER_SIZE {
+		return nil, nil, fmt.Errorf("body of %d bytes is shorter than the %d bytes content coding header", len(body), HEADER_SIZE)
+	}
+
+	idlen := int(body[HEADER_SIZE-1])
+
+	if len(body) < HEADER_SIZE+idlen {
+		return nil, nil, fmt.Errorf("body of %d bytes is shorter than the key ID of %d bytes", len(body), idlen)
+	}
+
+	header = &ContentCodingHeader{
+		Salt:       body[:SALT_SIZE],
+		RecordSize: binary.BigEndian.Uint32(body[SALT_SIZE : SALT_SIZE+RECORD_SIZE]),
+		KeyId:      body[HEADER_SIZE : HEADER_SIZE+idlen],
+	}
+	ciphertext = body[HEADER_SIZE+idlen:]
+
+	if header.RecordSize < MIN_RECORD_SIZE {
+		return header, ciphertext, fmt.Errorf("record size of %d bytes is smaller than %d bytes", header.RecordSize, MIN_RECORD_SIZE)
+	}
+
+	// web push messages consist of a single record, see https://datatracker.ietf.org/doc/html/rfc8291#section-4
+	if uint32(len(ciphertext)) > header.RecordSize {
+		return header, ciphertext, fmt.Errorf("ciphertext of %d bytes exceeds the record size of %d bytes", len(ciphertext), header.RecordSize)
+	}
+
+	if idlen != KEY_ID_SIZE {
+		return header, ciphertext, fmt.Errorf("key ID has %d bytes, expected a %d bytes public key", idlen, KEY_ID_SIZE)
+	}
+
+	if header.PublicKey, err = decodePublicKey(header.KeyId); err != nil {
+		return header, ciphertext, err
+	}
+
+	return
+}
+
+// Decrypt decrypts a body produced by WebPush.Encrypt using the user agent's private key and auth secret,
+// and validates its padding, see https://datatracker.ietf.org/doc/html/rfc8291#section-3.4
+func Decrypt(body []byte, privateKey *ecdh.PrivateKey, authSecret []byte) (record *DecryptedRecord, err error) {
+	header, ciphertext, err := ParseContentCodingHeader(body)
+	if err != nil {
+		return
+	}
+
+	var sharedSecret []byte
+
+	if sharedSecret, err = privateKey.ECDH(header.PublicKey); err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+
+	keyInfo := append([]byte("WebPush: info"), 0x00)
+	keyInfo = append(keyInfo, privateKey.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, header.KeyId...)
+
+	var ikm []byte
+
+	if ikm, err = deriveKey(sharedSecret, authSecret, keyInfo, IKM_SIZE); err != nil {
+		return
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, header.Salt)
+
+	var cek, nonce []byte
+
+	if cek, err = generateContentEncryptionKey(prk); err != nil {
+		return
+	}
+
+	if nonce, err = generateNonce(prk); err != nil {
+		return
+	}
+
+	var block cipher.Block
+
+	if block, err = aes.NewCipher(cek); err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	var gcm cipher.AEAD
+
+	if gcm, err = cipher.NewGCM(block); err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	var plaintext []byte
+
+	if plaintext, err = gcm.Open(nil, nonce, ciphertext, nil); err != nil {
+		return nil, fmt.Errorf("failed to decrypt record, the private key or auth secret may be wrong: %w", err)
+	}
+
+	// the plaintext is followed by the delimiter and zero padding
+	delimiter := len(plaintext) - 1
+
+	for delimiter >= 0 && plaintext[delimiter] == 0x00 {
+		delimiter--
+	}
+
+	if delimiter < 0 {
+		return nil, fmt.Errorf("record contains no padding delimiter")
+	}
+
+	record = &DecryptedRecord{
+		Plaintext:     plaintext[:delimiter],
+		Delimiter:     plaintext[delimiter],
+		PaddingLength: len(plaintext) - delimiter - 1,
+	}
+
+	if record.Delimiter != RECORD_DELIMITER {
+		return record, fmt.Errorf("record delimiter is 0x%02x, expected 0x%02x for the last record", record.Delimiter, RECORD_DELIMITER)
+	}
+
+	return
+}
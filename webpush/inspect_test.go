@@ -0,0 +1,79 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/saschazar21/go-web-push-server/models"
+	"github.com/saschazar21/go-web-push-server/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixtures taken from Appendix A. of https://datatracker.ietf.org/doc/rfc8291/
+func TestDecryptFixtures(t *testing.T) {
+	var (
+		plainText  = "When I grow up, I want to be a watermelon"
+		result     = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+		authSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+		uaPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+		asPublic   = "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"
+	)
+
+	body, _ := base64.RawURLEncoding.DecodeString(result)
+	authSecretBuf, _ := base64.RawURLEncoding.DecodeString(authSecret)
+
+	privKey, err := testDecodePrivateKey(uaPrivate)
+	assert.NoError(t, err)
+
+	header, ciphertext, err := ParseContentCodingHeader(body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "DGv6ra1nlYgDCS1FRnbzlw", base64.RawURLEncoding.EncodeToString(header.Salt))
+	assert.Equal(t, uint32(4096), header.RecordSize)
+	assert.Equal(t, asPublic, base64.RawURLEncoding.EncodeToString(header.PublicKey.Bytes()))
+	assert.Len(t, ciphertext, len(body)-HEADER_SIZE-KEY_ID_SIZE)
+
+	record, err := Decrypt(body, privKey, authSecretBuf)
+	assert.NoError(t, err)
+
+	assert.Equal(t, plainText, string(record.Plaintext))
+	assert.Equal(t, byte(RECORD_DELIMITER), record.Delimiter)
+	assert.Equal(t, 0, record.PaddingLength)
+
+	_, err = Decrypt(body, privKey, make([]byte, 16))
+	assert.ErrorContains(t, err, "failed to decrypt record")
+
+	_, _, err = ParseContentCodingHeader(body[:HEADER_SIZE-1])
+	assert.ErrorContains(t, err, "shorter than")
+}
+
+func TestDecryptPadded(t *testing.T) {
+	privKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	p256dh := privKey.PublicKey().Bytes()
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+
+	endpoint := "https://fcm.googleapis.com/fcm/send/abc"
+
+	push, err := NewWebPush(&models.PushSubscription{
+		Endpoint: (*utils.EncryptedString)(&endpoint),
+		Keys: &models.SubscriptionKeys{
+			P256DH:     (*utils.EncryptedBytes)(&p256dh),
+			AuthSecret: (*utils.EncryptedBytes)(&authSecret),
+		},
+	})
+	assert.NoError(t, err)
+
+	body, err := push.Encrypt([]byte("Hello, World!"))
+	assert.NoError(t, err)
+
+	record, err := Decrypt(body, privKey, authSecret)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Hello, World!", string(record.Plaintext))
+	assert.Equal(t, MAX_PLAINTEXT_SIZE-len("Hello, World!"), record.PaddingLength)
+}
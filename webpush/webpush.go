@@ -38,13 +38,21 @@ type WebPush struct {
 	Salt      [SALT_SIZE]byte
 }
 
+func ValidatePayloadSize(payload []byte) (err error) {
+	if len(payload) > MAX_PLAINTEXT_SIZE {
+		errorPayload := errors.NewErrorResponse(http.StatusRequestEntityTooLarge, "Push message body is too large", fmt.Sprintf("For compatibility reasons, the push message body must not exceed %d bytes.", MAX_PLAINTEXT_SIZE))
+
+		return errors.NewResponseError(errorPayload, http.StatusRequestEntityTooLarge)
+	}
+
+	return
+}
+
 func (p *WebPush) encrypt(payload []byte) (buf []byte, err error) {
 	buf = payload
 
-	if len(buf) > MAX_PLAINTEXT_SIZE {
-		errorPayload := errors.NewErrorResponse(http.StatusRequestEntityTooLarge, "Push message body is too large", fmt.Sprintf("For compatibility reasons, the push message body must not exceed %d bytes.", MAX_PLAINTEXT_SIZE))
-
-		return nil, errors.NewResponseError(errorPayload, http.StatusRequestEntityTooLarge)
+	if err = ValidatePayloadSize(buf); err != nil {
+		return nil, err
 	}
 
 	padding := make([]byte, MAX_PLAINTEXT_SIZE-len(buf))
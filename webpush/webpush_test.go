@@ -194,6 +194,9 @@ MK468C66gOKehSQqxUQ8+HCI/g==
 -----END PRIVATE KEY-----	
 `)
 	t.Setenv(utils.VAPID_SUBJECT_ENV, "test@example.com")
+	t.Setenv(utils.ENDPOINT_ALLOW_INSECURE_ENV, "true")
+	t.Setenv(utils.ENDPOINT_ALLOW_PRIVATE_IPS_ENV, "true")
+	t.Setenv(utils.ENDPOINT_ALLOW_UNKNOWN_PUSH_SERVICES_ENV, "true")
 
 	testServer := httptest.NewServer(http.HandlerFunc(webpush_test.EchoHeaders))
 